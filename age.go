@@ -177,3 +177,15 @@ func (a *age) compactString() string {
 
 	return fmt.Sprintf("%ds", a.seconds)
 }
+
+// futureString returns the compactString() of how far the age is in the future prefixed
+// with a "+", as in "+3D". Ages which are not in the future are returned as per
+// compactString().
+func (a *age) futureString() string {
+	if a.seconds >= 0 {
+		return a.compactString()
+	}
+	f := age{seconds: -a.seconds}
+
+	return "+" + f.compactString()
+}
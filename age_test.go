@@ -155,3 +155,24 @@ func TestAgeCompactString(t *testing.T) {
 		}
 	}
 }
+
+func TestAgeFutureString(t *testing.T) {
+	testCases := []struct {
+		seconds int64
+		expect  string
+	}{
+		{-86401 * 3, "+3D"},
+		{-59, "+59s"},
+		{0, "0s"},
+		{61, "1m"},
+	}
+
+	var a age
+	for ix, tc := range testCases {
+		a.seconds = tc.seconds
+		s := a.futureString()
+		if s != tc.expect {
+			t.Error(ix, "Expect", tc.expect, "got", s)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// archiveLoadFunc loads the archive at path as a virtual file system.
+type archiveLoadFunc func(path string) (fs.FS, error)
+
+// archiveSeparator separates the archive path from the path within the archive when
+// displaying entries, as in "backup.tar!/etc/hosts".
+const archiveSeparator = "!"
+
+// archiveLoaders maps archive suffixes to their loader. Longer suffixes must precede
+// shorter suffixes which they contain.
+var archiveLoaders = []struct {
+	suffix string
+	load   archiveLoadFunc
+}{
+	{".tar.gz", loadTarGzip},
+	{".tar.zst", loadTarZstd},
+	{".tgz", loadTarGzip},
+	{".tar", loadTar},
+
+	{".zip", loadZip}, // And all the formats which are zips in disguise
+	{".jar", loadZip},
+	{".war", loadZip},
+	{".ear", loadZip},
+	{".apk", loadZip},
+	{".whl", loadZip},
+	{".epub", loadZip},
+	{".docx", loadZip},
+	{".xlsx", loadZip},
+	{".pptx", loadZip},
+	{".odt", loadZip},
+	{".ods", loadZip},
+	{".odp", loadZip},
+
+	{".mtree", loadMtree}, // Not an archive, but a description of one
+}
+
+// archiveLoader returns the loader for path based on its suffix or nil if path does not
+// look like a supported archive. The suffix comparison is case-insensitive.
+func archiveLoader(path string) archiveLoadFunc {
+	lower := strings.ToLower(path)
+	for _, al := range archiveLoaders {
+		if strings.HasSuffix(lower, al.suffix) {
+			return al.load
+		}
+	}
+
+	return nil
+}
+
+func loadTar(path string) (fs.FS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readTar(path, f)
+}
+
+func loadTarGzip(path string) (fs.FS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer zr.Close()
+
+	return readTar(path, zr)
+}
+
+// loadTarZstd relies on an external zstd command as the standard library has no zstd
+// decompressor and we have no wish to take on dependencies for one archive format.
+func loadTarZstd(path string) (fs.FS, error) {
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		return nil, fmt.Errorf("%s: zstd command needed to decompress: %w", path, err)
+	}
+	cmd := exec.Command(zstd, "-dcq", path)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	fsys, tarErr := readTar(path, out)
+	io.Copy(io.Discard, out) // Drain so zstd can exit if tar stopped early
+	err = cmd.Wait()
+	if tarErr != nil {
+		return nil, tarErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: zstd: %w %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return fsys, nil
+}
+
+// readTar constructs a memFS from the headers in the tar stream. Content is skipped.
+func readTar(path string, r io.Reader) (fs.FS, error) {
+	mfs := newMemFS()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeXGlobalHeader, tar.TypeXHeader, tar.TypeGNULongName, tar.TypeGNULongLink:
+			continue // Meta headers which the tar package should have consumed
+		}
+
+		sd := statDetails{hasOwner: true, uid: uint32(hdr.Uid), gid: uint32(hdr.Gid)}
+		err = mfs.add(hdr.Name, hdr.FileInfo().Mode(), hdr.ModTime, sd)
+		if errors.Is(err, fs.ErrInvalid) { // Unrepresentable name - just skip
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return mfs, nil
+}
+
+// loadZip constructs a memFS from the zip central directory. While zip.Reader is an fs.FS
+// in its own right, it gives implied directories a zero modification time and requires
+// the archive to remain open for the duration of the scan.
+func loadZip(path string) (fs.FS, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	mfs := newMemFS()
+	for _, f := range zr.File {
+		err = mfs.add(f.Name, f.Mode(), f.Modified, statDetails{})
+		if errors.Is(err, fs.ErrInvalid) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return mfs, nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTarEntries are written to all test archives. "src" is the most recently active
+// directory and "docs" has an older entry.
+var testTarEntries = []struct {
+	name   string
+	flag   byte
+	offset time.Duration
+}{
+	{"./", tar.TypeDir, -10 * time.Hour},
+	{"./docs/", tar.TypeDir, -10 * time.Hour},
+	{"./docs/README.md", tar.TypeReg, -3 * time.Hour},
+	{"./src/main.go", tar.TypeReg, -1 * time.Hour},
+	{"./src/main.o", tar.TypeReg, -2 * time.Hour},
+	{"./src/.git/HEAD", tar.TypeReg, -1 * time.Minute}, // Ignored by default
+	{"./src/link", tar.TypeSymlink, -4 * time.Hour},
+}
+
+func testWriteTar(t *testing.T, w io.Writer, now time.Time) {
+	tw := tar.NewWriter(w)
+	for _, e := range testTarEntries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.flag, Mode: 0644,
+			ModTime: now.Add(e.offset), Linkname: "main.go"}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testArchiveScan scans path as a command line root and returns the candidate paths in
+// activity order.
+func testArchiveScan(t *testing.T, path string) ([]string, string) {
+	var stderr bytes.Buffer
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+		func() (string, error) { return "", nil })
+	scn, can, err := testScannerSetup(cfg, &stderr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scn.descendRoot(path)
+	scn.wait()
+	can.sortAscending()
+
+	var paths []string
+	for _, c := range can.cf {
+		paths = append(paths, c.path)
+	}
+
+	return paths, stderr.String()
+}
+
+func TestArchiveTar(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	var plain bytes.Buffer
+	testWriteTar(t, &plain, now)
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	zw.Write(plain.Bytes())
+	zw.Close()
+
+	files := map[string][]byte{
+		"backup.tar":    plain.Bytes(),
+		"backup.tar.gz": zipped.Bytes(),
+		"backup.TGZ":    zipped.Bytes(),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		paths, stderr := testArchiveScan(t, path)
+		exp := path + "!/src/main.go|" + path + "!/docs/README.md"
+		got := strings.Join(paths, "|")
+		if got != exp {
+			t.Errorf("%s: Expected '%s' got '%s' stderr '%s'\n", name, exp, got, stderr)
+		}
+	}
+}
+
+// TestArchiveImpliedDirs checks that directories which are only implied by the names of
+// archive entries never appear to have deletion-only activity.
+func TestArchiveImpliedDirs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "t.tar")
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "a/b/f", Typeflag: tar.TypeReg, Mode: 0644,
+		ModTime: time.Now().Add(-2 * time.Hour)})
+	tw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		exp  string
+	}{
+		{[]string{"-age", "99Y"}, "2h:f:" + path + "!/a/b/f\n"},
+		{[]string{"-age", "99Y", "-pdeleted"}, ""},
+	} {
+		var stdout, stderr bytes.Buffer
+		ec := realMain(time.Now(), append(tc.args, path), func() (string, error) { return "", nil },
+			noEnv, nil, &stdout, &stderr)
+		if ec != EX_OK || stdout.String() != tc.exp {
+			t.Errorf("%v: Expected %q got %d %q %s", tc.args, tc.exp, ec, stdout.String(), stderr.String())
+		}
+	}
+}
+
+func TestArchiveZip(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range testTarEntries {
+		if e.flag != tar.TypeReg { // Zip directories are implied
+			continue
+		}
+		fh := &zip.FileHeader{Name: strings.TrimPrefix(e.name, "./"), Modified: now.Add(e.offset)}
+		if _, err := zw.CreateHeader(fh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"bundle.zip", "component.jar", "report.docx"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+		paths, stderr := testArchiveScan(t, path)
+		exp := path + "!/src/main.go|" + path + "!/docs/README.md"
+		got := strings.Join(paths, "|")
+		if got != exp {
+			t.Errorf("%s: Expected '%s' got '%s' stderr '%s'\n", name, exp, got, stderr)
+		}
+	}
+}
+
+func TestArchiveTarZstd(t *testing.T) {
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd command not available")
+	}
+
+	dir := t.TempDir()
+	tarPath := filepath.Join(dir, "backup.tar")
+	var plain bytes.Buffer
+	testWriteTar(t, &plain, time.Now())
+	if err := os.WriteFile(tarPath, plain.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(zstd, "-q", tarPath).CombinedOutput()
+	if err != nil {
+		t.Fatal(string(out), err)
+	}
+
+	paths, stderr := testArchiveScan(t, tarPath+".zst")
+	if len(paths) != 2 || !strings.HasSuffix(paths[0], ".tar.zst!/src/main.go") {
+		t.Error("Unexpected zstd results", paths, stderr)
+	}
+}
+
+func TestArchiveErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"corrupt.tar.gz", "corrupt.tar", "corrupt.zip"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("This is not an archive at all, at all"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		paths, stderr := testArchiveScan(t, path)
+		if len(paths) != 0 || !strings.Contains(stderr, "Loading Archive") {
+			t.Error(name, "Expected archive load error, got", paths, stderr)
+		}
+	}
+
+	// A directory with an archive suffix is scanned as a directory
+	path := filepath.Join(dir, "dir.tar")
+	if err := os.Mkdir(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr := testArchiveScan(t, path)
+	if len(stderr) > 0 {
+		t.Error("Unexpected error scanning directory", stderr)
+	}
+}
+
+func TestArchiveLoader(t *testing.T) {
+	for _, name := range []string{"a.tar", "a.TAR.gz", "a.tgz", "a.tar.zst", "a.zip", "a.Jar", "a.xlsx"} {
+		if archiveLoader(name) == nil {
+			t.Error(name, "should have a loader")
+		}
+	}
+	for _, name := range []string{"a.gz", "tar", "a.zst", "a.tar.bz2"} {
+		if archiveLoader(name) != nil {
+			t.Error(name, "should not have a loader")
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"io/fs"
+	"path/filepath"
 	"sort"
 	"sync"
 	"time"
@@ -24,14 +25,20 @@ func newCandidates(maxEntries int, maxAge age) *candidates {
 // printed at the end of all scanning. It's called a candidate because it can be evicted
 // by a younger candidate.
 type candidate struct {
-	path string
-	mode fs.FileMode
-	age  age
+	path    string
+	mode    fs.FileMode
+	modTime time.Time // Retained so age can be recalculated against a later base time
+	age     age
+	owner   statDetails // Only meaningful if owner.hasOwner is true
+	exts    string      // Summary of recently modified extensions if -pexts is set
+
+	deleted bool // Directory's own DTM is the youngest, so activity was a deletion
 }
 
 func (c *candidate) set(path string, mode fs.FileMode, baseTime, modTime time.Time) {
 	c.path = path
 	c.mode = mode
+	c.modTime = modTime
 	c.age.setFromTime(baseTime, modTime)
 }
 
@@ -44,8 +51,24 @@ func (c *candidate) isDir() bool {
 	return c.mode.IsDir()
 }
 
-// ftype returns a printable rendition of the file system type of the candidate
+// dirName returns the directory of the candidate as printed by -pdirname. A deletion is
+// already a directory.
+func (c *candidate) dirName() string {
+	p := filepath.Clean(c.path)
+	if c.deleted {
+		return p
+	}
+
+	return filepath.Dir(p)
+}
+
+// ftype returns a printable rendition of the file system type of the candidate. A
+// directory with deletion-only activity is given the pseudo-type fTypeDeleted.
 func (c *candidate) fType() string {
+	if c.deleted {
+		return fTypeDeleted
+	}
+
 	return fTypeString(c.mode)
 }
 
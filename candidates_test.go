@@ -105,6 +105,12 @@ func TestCandidateFType(t *testing.T) {
 	if len(expect) > 0 {
 		t.Error("Should not be any residual types in", expect)
 	}
+
+	c.mode = fs.ModeDir
+	c.deleted = true
+	if c.fType() != fTypeDeleted {
+		t.Error("Deleted directory should have type", fTypeDeleted, "not", c.fType())
+	}
 }
 
 func TestCandidatesMaxAgeWidth(t *testing.T) {
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Exit codes of -expect-active and -expect-quiet as understood by Nagios, Icinga and
+// other monitoring systems which run plugins. These replace the EX_* codes.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStatus = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+const checkListMax = 5 // Maximum directories named in the summary
+
+// checkDirs returns the youngest activity within each directory scanned at depth below a
+// root, including the activity of all its sub-directories. Directories without any
+// qualifying activity map to nil. Activity must be tracked.
+func (scn *scanner) checkDirs(depth uint) map[string]*candidate {
+	dirs := make(map[string]*candidate)
+	for dir, sDir := range scn.scanned {
+		if sDir.depth == depth {
+			dirs[dir] = nil
+		}
+	}
+	for dir, c := range scn.activity {
+		for p := dir; ; p = filepath.Dir(p) {
+			if youngest, ok := dirs[p]; ok {
+				if youngest == nil || c.age.lt(youngest.age) {
+					dirs[p] = c
+				}
+				break
+			}
+			if filepath.Dir(p) == p {
+				break
+			}
+		}
+	}
+
+	return dirs
+}
+
+// unscannedRoots returns the command line paths which could not be scanned, such as those
+// which do not exist, as nothing below them can be checked. Activity must be tracked.
+func (scn *scanner) unscannedRoots() (roots []string) {
+	scannedRoots := make(map[string]bool)
+	for _, sDir := range scn.scanned {
+		if sDir.depth == 0 && sDir.name == "." {
+			scannedRoots[strings.TrimSuffix(sDir.tree.prefix, archiveSeparator)] = true
+		}
+	}
+	for _, root := range scn.rootPaths {
+		if !scannedRoots[root] {
+			roots = append(roots, root)
+		}
+	}
+
+	return
+}
+
+// check writes a one line summary in the style of a monitoring plugin and returns the
+// plugin exit code. The status is critical if any command line path could not be
+// scanned, or if any directory at depth has no activity within active, or has activity
+// within quiet, when they are set. Otherwise access errors make the status a warning as
+// activity may have been missed. Performance data follows the "|" separator.
+func (scn *scanner) check(out io.Writer, depth uint, active, quiet age) int {
+	dirs := scn.checkDirs(depth)
+	unscanned := scn.unscannedRoots()
+	var inactive, busy []string
+	for dir, c := range dirs {
+		if active.seconds > 0 && (c == nil || c.age.gt(active, false)) {
+			inactive = append(inactive, dir)
+		}
+		if quiet.seconds > 0 && c != nil && c.age.le(quiet) {
+			busy = append(busy, dir)
+		}
+	}
+
+	var status int
+	var details []string
+	switch {
+	case len(unscanned) > 0 || len(inactive) > 0 || len(busy) > 0:
+		status = checkCritical
+		if len(unscanned) > 0 {
+			details = append(details, fmt.Sprintf("%d not scanned: %s",
+				len(unscanned), checkList(unscanned)))
+		}
+		if len(inactive) > 0 {
+			details = append(details, fmt.Sprintf("%d of %d not active within %s: %s",
+				len(inactive), len(dirs), active.value, checkList(inactive)))
+		}
+		if len(busy) > 0 {
+			details = append(details, fmt.Sprintf("%d of %d active within %s: %s",
+				len(busy), len(dirs), quiet.value, checkList(busy)))
+		}
+	case len(dirs) == 0:
+		status = checkUnknown
+		details = append(details, fmt.Sprintf("no directories at depth %d", depth))
+	default:
+		if scn.errorCount > 0 {
+			status = checkWarning
+			details = append(details, fmt.Sprintf("%d access errors", scn.errorCount))
+		}
+		if active.seconds > 0 {
+			details = append(details, fmt.Sprintf("%d active within %s", len(dirs), active.value))
+		}
+		if quiet.seconds > 0 {
+			details = append(details, fmt.Sprintf("%d quiet for %s", len(dirs), quiet.value))
+		}
+	}
+
+	fmt.Fprintf(out, "%s %s - %s | directories=%d inactive=%d busy=%d errors=%d\n",
+		strings.ToUpper(Name), checkStatus[status], strings.Join(details, ", "),
+		len(dirs), len(inactive), len(busy), scn.errorCount)
+
+	return status
+}
+
+// checkList returns the sorted directories as a comma separated list truncated to
+// checkListMax entries so that the summary remains a readable single line.
+func checkList(dirs []string) string {
+	sort.Strings(dirs)
+	if len(dirs) <= checkListMax {
+		return strings.Join(dirs, ", ")
+	}
+
+	return fmt.Sprintf("%s and %d more", strings.Join(dirs[:checkListMax], ", "), len(dirs)-checkListMax)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	base := t.TempDir()
+	now := time.Now()
+	for _, f := range []struct {
+		path   string
+		offset time.Duration
+	}{ // Parents precede children so that directory times are not disturbed
+		{"spool/", -3 * 24 * time.Hour},
+		{"spool/empty/", -3 * 24 * time.Hour},
+		{"spool/busy/job", -time.Minute},
+		{"spool/busy/", -3 * 24 * time.Hour},
+		{"spool/slow/deep/job", -2 * time.Hour},
+		{"spool/slow/deep/", -3 * 24 * time.Hour},
+		{"spool/slow/", -3 * 24 * time.Hour},
+		{"spool/stale/job", -3 * 24 * time.Hour},
+		{"spool/stale/", -3 * 24 * time.Hour},
+	} {
+		path := filepath.Join(base, filepath.FromSlash(f.path))
+		if strings.HasSuffix(f.path, "/") {
+			os.MkdirAll(path, 0700)
+		} else {
+			os.MkdirAll(filepath.Dir(path), 0700)
+			os.WriteFile(path, nil, 0600)
+		}
+		mtime := now.Add(f.offset)
+		os.Chtimes(path, mtime, mtime)
+	}
+	os.Chtimes(filepath.Join(base, "spool"), now.Add(-3*24*time.Hour), now.Add(-3*24*time.Hour))
+	spool := filepath.Join(base, "spool")
+	name := func(dir string) string { return filepath.Join(spool, dir) }
+
+	testCases := []struct {
+		args   []string
+		excode int
+		out    string
+	}{
+		{[]string{"-expect-active", "1h"}, checkOK,
+			"FAD OK - 1 active within 1h | directories=1 inactive=0 busy=0 errors=0\n"},
+		{[]string{"-expect-quiet", "5m"}, checkCritical,
+			"FAD CRITICAL - 1 of 1 active within 5m: " + spool + " | directories=1 inactive=0 busy=1 errors=0\n"},
+		{[]string{"-expect-active", "1D", "-expect-depth", "1"}, checkCritical,
+			"FAD CRITICAL - 2 of 4 not active within 1D: " + name("empty") + ", " + name("stale") +
+				" | directories=4 inactive=2 busy=0 errors=0\n"},
+		{[]string{"-expect-active", "1h", "-expect-quiet", "30s"}, checkOK,
+			"FAD OK - 1 active within 1h, 1 quiet for 30s | directories=1 inactive=0 busy=0 errors=0\n"},
+		{[]string{"-expect-active", "1h", "-expect-quiet", "5m", "-expect-depth", "1"}, checkCritical,
+			"FAD CRITICAL - 3 of 4 not active within 1h: " + name("empty") + ", " + name("slow") + ", " +
+				name("stale") + ", 1 of 4 active within 5m: " + name("busy") +
+				" | directories=4 inactive=3 busy=1 errors=0\n"},
+		{[]string{"-expect-active", "1h", "-expect-depth", "4"}, checkUnknown,
+			"FAD UNKNOWN - no directories at depth 4 | directories=0 inactive=0 busy=0 errors=0\n"},
+		{[]string{"-expect-active", "1h", "-q", filepath.Join(base, "noexist")}, checkCritical,
+			"FAD CRITICAL - 1 not scanned: " + filepath.Join(base, "noexist") +
+				" | directories=1 inactive=0 busy=0 errors=1\n"},
+		{[]string{"-expect-quiet", "1m", "-expect-depth", "1", "-q", filepath.Join(base, "noexist")},
+			checkCritical, "FAD CRITICAL - 1 not scanned: " + filepath.Join(base, "noexist") +
+				", 1 of 4 active within 1m: " + name("busy") + " | directories=4 inactive=0 busy=1 errors=1\n"},
+	}
+
+	for ix, tc := range testCases {
+		var stdout, stderr strings.Builder
+		args := append(tc.args, spool)
+		ec := realMain(now, args, func() (string, error) { return "", nil }, noEnv, nil, &stdout, &stderr)
+		if ec != tc.excode {
+			t.Error(ix, "Expected exit code", tc.excode, "got", ec, stderr.String())
+		}
+		if stdout.String() != tc.out {
+			t.Errorf("%d Expected\n%sGot\n%s", ix, tc.out, stdout.String())
+		}
+	}
+}
+
+func TestCheckList(t *testing.T) {
+	testCases := []struct {
+		dirs []string
+		exp  string
+	}{
+		{[]string{"b", "a"}, "a, b"},
+		{[]string{"g", "f", "e", "d", "c", "b", "a"}, "a, b, c, d, e and 2 more"},
+	}
+	for _, tc := range testCases {
+		got := checkList(tc.dirs)
+		if got != tc.exp {
+			t.Errorf("checkList(%v) got %q, expected %q", tc.dirs, got, tc.exp)
+		}
+	}
+}
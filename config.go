@@ -1,14 +1,17 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 )
 
 const (
@@ -29,6 +32,7 @@ const (
 	fTypeDir        = "d"
 	fTypeFile       = "f"
 	fTypeNamedPipe  = "p"
+	fTypeDeleted    = "x" // Pseudo-type of a directory whose own DTM is its activity date
 	fTypeUnknown    = "?"
 )
 
@@ -42,6 +46,7 @@ var (
 		fTypeDir:        fs.ModeDir,
 		fTypeFile:       0, // Regular file
 		fTypeNamedPipe:  fs.ModeNamedPipe,
+		fTypeDeleted:    fs.ModeDir, // Not a real type, but it only ever applies to dirs
 	}
 	validFTypesString string
 )
@@ -83,28 +88,70 @@ func fTypeString(mode fs.FileMode) string {
 // docFlags are only valid on the command-line and result in a documentation printout of
 // somesort followed by an exit.
 type docFlags struct {
-	help    bool
-	manpage bool
-	version bool
+	help      bool
+	manpage   bool
+	version   bool
+	shellInit string // Print the integration script for this shell
+}
+
+// commandFlags are only valid on the command-line and control what is scanned.
+type commandFlags struct {
+	fromFile string // Read paths to scan from this file or stdin if "-"
+	fromNul  bool   // Paths in fromFile are NUL terminated
+
+	listingFile string // Scan the find or ls listing in this file or stdin if "-"
+	watch       bool   // Keep the list current from file system events after the scan
+	tui         bool   // Browse the results interactively and print the selected directory
+	saveFile    string // Save the per-directory activity of the scan to this file
+	diffFile    string // Compare the scan with the activity previously saved to this file
+
+	sinceLastRun optionalFlag  // Only report activity since the last run with this name
+	interval     time.Duration // Repeat the scan at this interval and print the changes
+
+	execCmd   string // Run this command for each candidate
+	execBatch string // Run this command once with all candidates
+
+	serveAddr      string        // Serve scan results over HTTP on this address
+	rescanInterval time.Duration // Rescan served results older than this
+
+	format  string // Output format of the printed list - one of formatNames
+	profile string // Apply the defaults.conf profile with this name
+
+	expectActive ageFlag // Check that directories have activity within this age
+	expectQuiet  ageFlag // Check that directories have no activity within this age
+
+	jumpQuery string // Print the directory best matching this query
+	visitDir  string // Record a visit to this directory for -jump then exit
 }
 
 // configFlags can be changed by user configuration or command-line flags
 type configFlags struct {
+	printDeleted boolFlag // Print only directories with deletion-only activity
 	printDirname boolFlag // Print just the dirname of the path
+	printExts    boolFlag // Print a summary of recently modified extensions
+	printFuture  boolFlag // Print future-dated entries in a separate section
 	printIgnored boolFlag // Print file system objects ignored by ignore filters
+	printOwner   boolFlag // Print the owner of the entry conferring activity
 	printStats   boolFlag // Print scanning stats at end of program
 
 	suppressErrors boolFlag // Don't print errors if file-system access fails
+	ignoreFuture   boolFlag // Exclude future-dated entries from ranking
+	gitHistory     boolFlag // Use git commit times rather than file system times
 
 	maxAge      ageFlag  // Age limit of paths to print
 	maxCount    uintFlag // How many paths to print
 	maxDepth    uintFlag // Descend depth
 	maxScanners uintFlag // Maximum number of concurrent directory scanners
+	skew        ageFlag  // Future-dated tolerance before an entry is considered "fut"
+	expectDepth uintFlag // Directories this far below each root are checked
 
 	ignoreBases    commaStringFlag // Exact `basename` values to ignore
 	ignoreContains commaStringFlag // Caseless strings to ignore in full path
 	ignoreRegexes  commaStringFlag // Regexes to ignore in full path
 	ignoreTypes    commaStringFlag // Ignore file system types base on our notation (validFTypes)
+
+	ownerUsers  commaStringFlag // Only consider entries owned by these users
+	ownerGroups commaStringFlag // Only consider entries owned by these groups
 }
 
 // derivedConfig values are built from configFlags
@@ -114,20 +161,27 @@ type derivedConfig struct {
 	ignoreRegexesList     []string
 	ignoreRegexesCompiled []*regexp.Regexp
 	ignoreTypesMap        map[string]any
+	ownerUsersMap         map[uint32]any
+	ownerGroupsMap        map[uint32]any
 }
 
 // userConfigDirFunc defines the function which returns the location of the default
 // configuration directory. Defaults to os.UserConfigDir but is replaced by tests.
 type userConfigDirFunc func() (string, error)
 
+// envLookupFunc defines the function which returns the value of an environment variable.
+// Defaults to os.LookupEnv but is replaced by tests.
+type envLookupFunc func(key string) (string, bool)
+
 type config struct {
 	confFunc       userConfigDirFunc
 	configPathHelp string // Only used by -h
 
 	flagSet *flag.FlagSet
 
-	docFlags    // Command-line only
-	configFlags // Can be in config file or command-line
+	docFlags     // Command-line only
+	commandFlags // Command-line only
+	configFlags  // Can be in config file or command-line
 	derivedConfig
 }
 
@@ -135,8 +189,11 @@ type config struct {
 // default configuration file.
 func newConfig(fs *flag.FlagSet, confFunc userConfigDirFunc) *config {
 	cfg := &config{flagSet: fs, confFunc: confFunc}
+	cfg.sinceLastRun.defaultV = defaultLastRunName
 	cfg.ignoreBasesMap = make(map[string]any)
 	cfg.ignoreTypesMap = make(map[string]any)
+	cfg.ownerUsersMap = make(map[uint32]any)
+	cfg.ownerGroupsMap = make(map[uint32]any)
 
 	return cfg
 }
@@ -147,6 +204,50 @@ func (cfg *config) setFlags() {
 	cfg.flagSet.BoolVar(&cfg.manpage, "manpage", false, "Print manpage and exit - perhaps pipe into mandoc(1)")
 	cfg.flagSet.BoolVar(&cfg.version, "v", false, "Print version details and exit")
 	cfg.flagSet.BoolVar(&cfg.version, "version", false, "Print version details and exit")
+	cfg.flagSet.StringVar(&cfg.shellInit, "shell-init", "",
+		"Print the fcd function and completions for bash, fish or zsh, then exit")
+
+	cfg.flagSet.StringVar(&cfg.profile, "profile", "",
+		"Apply the named profile from the config file in addition to the base defaults")
+
+	cfg.flagSet.StringVar(&cfg.fromFile, "from", "",
+		"Read paths to scan from file ('-' for stdin) in addition to command line paths")
+	cfg.flagSet.BoolVar(&cfg.fromNul, "0", false, "Paths read by -from are NUL terminated, as with 'find -print0'")
+	cfg.flagSet.StringVar(&cfg.listingFile, "listing", "",
+		"Scan a 'find -printf \"%T@ %y %p\\n\"' or 'ls -lR --time-style=full-iso' listing ('-' for stdin)")
+
+	cfg.flagSet.StringVar(&cfg.saveFile, "save", "",
+		"Save the activity of every scanned directory to file for a later -diff")
+	cfg.flagSet.StringVar(&cfg.diffFile, "diff", "",
+		"Print directories which appeared, became active, went quiet or disappeared since -save")
+	cfg.flagSet.Var(&cfg.sinceLastRun, "since-last-run",
+		"Only print activity since the last run with the same name (-since-last-run=NAME)")
+	cfg.flagSet.BoolVar(&cfg.watch, "watch", false,
+		"After scanning, keep the list current from file system events until interrupted")
+	cfg.flagSet.DurationVar(&cfg.interval, "interval", 0,
+		"Repeat the scan at duration (e.g: 30s, 5m) and print rows which are new, moved or dropped")
+	cfg.flagSet.StringVar(&cfg.execCmd, "exec", "",
+		"Run command for each candidate instead of printing, replacing {dir} and {entry}")
+	cfg.flagSet.StringVar(&cfg.execBatch, "exec+", "",
+		"As -exec but run command once with args containing {dir} or {entry} repeated per candidate")
+	cfg.flagSet.StringVar(&cfg.serveAddr, "serve", "",
+		"Serve results as HTML and JSON over HTTP on address (e.g: localhost:8080)")
+	cfg.flagSet.DurationVar(&cfg.rescanInterval, "rescan", time.Minute,
+		"With -serve, rescan when results are older than duration (e.g: 30s, 5m, 0 for only on request)")
+	cfg.flagSet.StringVar(&cfg.format, "format", formatText,
+		"Print results as 'text' or as 'prometheus' metrics for the node_exporter textfile collector")
+	cfg.flagSet.Var(&cfg.expectActive, "expect-active",
+		"Exit with a monitoring plugin status which is critical unless there is activity within age")
+	cfg.flagSet.Var(&cfg.expectQuiet, "expect-quiet",
+		"Exit with a monitoring plugin status which is critical if there is activity within age")
+	cfg.flagSet.Var(&cfg.expectDepth, "expect-depth",
+		"Check every directory this far below the paths rather than the paths themselves")
+	cfg.flagSet.StringVar(&cfg.jumpQuery, "jump", "",
+		"Print the directory best matching the query, ranked by activity and -visit history")
+	cfg.flagSet.StringVar(&cfg.visitDir, "visit", "",
+		"Record a visit to the directory for -jump then exit - intended for a shell hook")
+	cfg.flagSet.BoolVar(&cfg.tui, "tui", false,
+		"Browse results in a full-screen view then print the selected directory on exit")
 
 	cfg.flagSet.Var(&cfg.maxAge, "age",
 		"Print paths no older than value (e.g: 1s, 2h, 3d, 4w, 5y)")
@@ -154,19 +255,36 @@ func (cfg *config) setFlags() {
 	cfg.flagSet.Var(&cfg.maxDepth, "depth",
 		"Maximum depth to descend below command line paths (default of 0 is unlimited)")
 
+	cfg.flagSet.Var(&cfg.gitHistory, "git",
+		"Use the last commit time of git tracked files rather than modification times")
+
 	cfg.flagSet.Var(&cfg.ignoreBases, "ibases", "Ignore paths which matching 'basename'")
 	cfg.flagSet.Var(&cfg.ignoreContains, "icontains",
 		"Ignore paths containing case-insensistive string ('"+string(os.PathSeparator)+"' allowed)")
 	cfg.flagSet.Var(&cfg.ignoreRegexes, "iregexes",
 		"Ignore paths matching patterns (see regexp.MatchString())")
+	cfg.flagSet.Var(&cfg.ignoreFuture, "ifuture",
+		"Ignore future-dated paths (beyond -skew) when determining activity")
 	cfg.flagSet.Var(&cfg.ignoreTypes, "itypes", "Ignore file system types")
 
+	cfg.flagSet.Var(&cfg.printDeleted, "pdeleted",
+		"Print only directories whose activity is a deletion (type 'x')")
 	cfg.flagSet.Var(&cfg.printDirname, "pdirname", "Print just the 'dirname' of paths")
+	cfg.flagSet.Var(&cfg.printExts, "pexts",
+		"Print a summary of extensions modified within -age for each directory")
+	cfg.flagSet.Var(&cfg.printFuture, "pfuture",
+		"Print future-dated paths (beyond -skew) in a separate section")
 	cfg.flagSet.Var(&cfg.printIgnored, "pignored", "Print paths ignored by filters")
+	cfg.flagSet.Var(&cfg.printOwner, "powner", "Print the owner of the entry conferring activity")
 	cfg.flagSet.Var(&cfg.printStats, "pstats", "Print summary statistics")
 
 	cfg.flagSet.Var(&cfg.suppressErrors, "q", "Suppress error messages when file-system access fails")
 	cfg.flagSet.Var(&cfg.maxScanners, "scanners", "Number directories to scan concurrently")
+	cfg.flagSet.Var(&cfg.skew, "skew",
+		"Tolerate clock skew of future-dated paths up to value (e.g: 30s, 5m)")
+
+	cfg.flagSet.Var(&cfg.ownerGroups, "group", "Only consider entries owned by these groups (name or gid)")
+	cfg.flagSet.Var(&cfg.ownerUsers, "user", "Only consider entries owned by these users (name or uid)")
 }
 
 // Set values which have not been previously set by caller
@@ -201,6 +319,14 @@ func (cfg *config) setInternalDefaults() {
 // comment-delimiter of '#' is ignored. There is no quoting mechanism nor
 // line-continuation support.
 //
+// A "[name]" line starts the named profile which continues until the next profile. Lines
+// prior to the first profile are the base which always applies. The lines of the profile
+// selected by -profile, if any, are applied after the base and thus override it, with
+// comma-strings prefixed with '+' appending to the base. Other profiles are checked for
+// errors but otherwise ignored. The same flag name can appear in the base and in each
+// profile, but duplicates within any one of them are an error, as are duplicate
+// profiles. If the selected profile is not present, errUnknownProfile is returned.
+//
 // Unlike command-line options, bools must be supplied with a true/false argument. This is
 // an arbitrary decision made the author as the visual of an isolated option seems
 // ambiguous.
@@ -216,7 +342,7 @@ func (cfg *config) loadDefaults() error {
 	}
 	if len(dir) == 0 { // Not sure this can occur in real-life, but treat as not existing
 		cfg.configPathHelp = "No UserConfigDir configure for this user"
-		return nil
+		return cfg.checkProfile(nil)
 	}
 	path := filepath.Join(dir, Name, defaultUserFile)
 	cfg.configPathHelp = path
@@ -225,31 +351,19 @@ func (cfg *config) loadDefaults() error {
 	if err != nil {
 		if os.IsNotExist(err) {
 			cfg.configPathHelp += " (not present)"
-			return nil // Not existing is not really an error
+			return cfg.checkProfile(nil) // Not existing is not really an error
 		}
 		cfg.configPathHelp += " " + err.Error()
 		return err // But all other errors are real errors
 	}
 
-	validOptions := map[string]flagValue{ // Listed in same order as configFlags
-		"pdirname": &cfg.printDirname,
-		"pignored": &cfg.printIgnored,
-		"pstats":   &cfg.printStats,
-
-		"q": &cfg.suppressErrors,
-
-		"age":      &cfg.maxAge,
-		"count":    &cfg.maxCount,
-		"depth":    &cfg.maxDepth,
-		"scanners": &cfg.maxScanners,
-
-		"ibases":    &cfg.ignoreBases,
-		"icontains": &cfg.ignoreContains,
-		"iregexes":  &cfg.ignoreRegexes,
-		"itypes":    &cfg.ignoreTypes,
-	}
+	validOptions := cfg.optionMap()
+	unselected := newConfig(nil, nil) // Other profiles are set here to check for errors
+	unselected.setInternalDefaults()
 
 	// Parse config file
+	options := validOptions
+	profiles := make(map[string]bool)
 	dupes := make(map[string]any)
 	for lno, line := range strings.Split(string(configText), "\n") {
 		line, _, _ = strings.Cut(line, "#")
@@ -258,10 +372,30 @@ func (cfg *config) loadDefaults() error {
 			continue
 		}
 
+		if strings.HasPrefix(fields[0], "[") { // Start of a profile
+			profile := fields[0]
+			if len(fields) > 1 || len(profile) < 3 || !strings.HasSuffix(profile, "]") {
+				return fmt.Errorf("Invalid profile '%s' at %s:%d",
+					strings.TrimSpace(line), cfg.configPathHelp, lno+1)
+			}
+			profile = profile[1 : len(profile)-1]
+			if profiles[profile] {
+				return fmt.Errorf("Duplicate profile '%s' at %s:%d",
+					profile, cfg.configPathHelp, lno+1)
+			}
+			profiles[profile] = true
+			dupes = make(map[string]any)
+			options = unselected.optionMap()
+			if profile == cfg.profile {
+				options = validOptions
+			}
+			continue
+		}
+
 		option := fields[0]
 		args := fields[1:]
 
-		fv, ok := validOptions[option]
+		fv, ok := options[option]
 		if !ok {
 			return fmt.Errorf("Unknown option '%s' at %s:%d",
 				option, cfg.configPathHelp, lno+1)
@@ -283,7 +417,113 @@ func (cfg *config) loadDefaults() error {
 		}
 	}
 
-	return nil
+	return cfg.checkProfile(profiles)
+}
+
+// envPrefix is prepended to the upper-cased option names to form the names of the
+// environment variables which override defaults.conf.
+var envPrefix = strings.ToUpper(Name) + "_"
+
+// loadEnv applies the environment variables named after the options which can be set in
+// the config file, such as FAD_AGE for "age" and FAD_EXPECT_DEPTH for "expect-depth",
+// with exactly the same semantics as the config file. Thus bools must be supplied with a
+// true/false value and comma-strings prefixed with '+' append to the value from the
+// config file. lookup is normally os.LookupEnv. Every valid variable is applied and an
+// error is returned for each invalid variable, in name order, so that one mistake does
+// not silently discard the others. Other variables with the prefix are ignored as the
+// environment is a shared namespace, as are the command-line only options.
+func (cfg *config) loadEnv(lookup envLookupFunc) (errs []error) {
+	options := cfg.optionMap()
+	names := make([]string, 0, len(options))
+	for option := range options {
+		names = append(names, option)
+	}
+	sort.Strings(names)
+
+	for _, option := range names {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(option, "-", "_"))
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		err := options[option].Set(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Environment %s: %w", name, err))
+		}
+	}
+
+	return
+}
+
+// errUnknownProfile means that the profile selected by -profile is not in the config file.
+var errUnknownProfile = errors.New("Unknown profile")
+
+// checkProfile returns errUnknownProfile if a profile is selected and it is not one of
+// the profiles present in the config file.
+func (cfg *config) checkProfile(profiles map[string]bool) error {
+	if len(cfg.profile) == 0 || profiles[cfg.profile] {
+		return nil
+	}
+
+	return fmt.Errorf("%w '%s' in %s", errUnknownProfile, cfg.profile, cfg.configPathHelp)
+}
+
+// selectedProfile returns the value of -profile in args. As the profile determines the
+// defaults, which must be loaded before args are parsed, args are first parsed with a
+// throwaway flag set. Any errors are left for the real parse to report.
+func selectedProfile(args []string) string {
+	fs := flag.NewFlagSet(Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg := newConfig(fs, nil)
+	cfg.setFlags()
+	fs.Parse(args)
+
+	return cfg.profile
+}
+
+// optionMap returns the options which can be set by name, such as from defaults.conf,
+// mapped to their values.
+func (cfg *config) optionMap() map[string]flagValue {
+	return map[string]flagValue{ // Listed in same order as configFlags
+		"pdeleted": &cfg.printDeleted,
+		"pdirname": &cfg.printDirname,
+		"pexts":    &cfg.printExts,
+		"pfuture":  &cfg.printFuture,
+		"pignored": &cfg.printIgnored,
+		"powner":   &cfg.printOwner,
+		"pstats":   &cfg.printStats,
+
+		"q":       &cfg.suppressErrors,
+		"ifuture": &cfg.ignoreFuture,
+		"git":     &cfg.gitHistory,
+
+		"age":          &cfg.maxAge,
+		"count":        &cfg.maxCount,
+		"depth":        &cfg.maxDepth,
+		"scanners":     &cfg.maxScanners,
+		"skew":         &cfg.skew,
+		"expect-depth": &cfg.expectDepth,
+
+		"ibases":    &cfg.ignoreBases,
+		"icontains": &cfg.ignoreContains,
+		"iregexes":  &cfg.ignoreRegexes,
+		"itypes":    &cfg.ignoreTypes,
+
+		"user":  &cfg.ownerUsers,
+		"group": &cfg.ownerGroups,
+	}
+}
+
+// flagGiven returns true if the named flag was set on the command line, as opposed to
+// retaining its default or a value from defaults.conf. Only meaningful after parsing.
+func (cfg *config) flagGiven(name string) (given bool) {
+	cfg.flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+
+	return
 }
 
 // Determine derived values from base config values. Generally be tolerant of "errors"
@@ -321,5 +561,5 @@ func (cfg *config) compile() error {
 		}
 	}
 
-	return nil
+	return cfg.compileOwners()
 }
@@ -34,9 +34,27 @@ func TestConfigLoadBasic(t *testing.T) {
 	if cfg.printDirname.v != true {
 		t.Error("printDirname should be true, not", cfg.printDirname)
 	}
+	if cfg.printExts.v != true {
+		t.Error("pexts should be true, not", cfg.printExts)
+	}
+	if cfg.printFuture.v != true {
+		t.Error("pfuture should be true, not", cfg.printFuture)
+	}
+	if cfg.ignoreFuture.v != true {
+		t.Error("ifuture should be true, not", cfg.ignoreFuture)
+	}
+	if cfg.gitHistory.v != true {
+		t.Error("git should be true, not", cfg.gitHistory)
+	}
+	if cfg.skew.seconds != 300 {
+		t.Error("skew should be 5m, not", cfg.skew.seconds)
+	}
 	if cfg.printIgnored.v != true {
 		t.Error("pignored should be true, not", cfg.printIgnored)
 	}
+	if cfg.printOwner.v != true {
+		t.Error("powner should be true, not", cfg.printOwner)
+	}
 	if cfg.printStats.v != true {
 		t.Error("pstats should be true, not", cfg.printStats)
 	}
@@ -66,6 +84,12 @@ func TestConfigLoadBasic(t *testing.T) {
 	if cfg.ignoreTypes.v != "p,d" {
 		t.Error("ipattern should be 'p,d', not", cfg.ignoreTypes)
 	}
+	if cfg.ownerUsers.v != "0" {
+		t.Error("user should be '0', not", cfg.ownerUsers)
+	}
+	if cfg.ownerGroups.v != "0,1" {
+		t.Error("group should be '0,1', not", cfg.ownerGroups)
+	}
 }
 
 func TestConfigLoadErrors(t *testing.T) {
@@ -132,3 +156,128 @@ func TestConfigCompile(t *testing.T) {
 		t.Error("Error does not contain", exp, got)
 	}
 }
+
+func TestConfigLoadProfiles(t *testing.T) {
+	testCases := []struct {
+		configFile string
+		profile    string
+		error      string
+		count      uint
+		depth      uint
+		age        int64
+		ibases     string
+		pdirname   bool
+	}{
+		{"profiles", "", "", 5, 0, 0, ".cache", true},
+		{"profiles", "projects", "", 5, 10, 7 * 86400, ".cache,.git", true},
+		{"profiles", "logs", "", 20, 2, 600, ".cache", false},
+		{"profiles", "nope", "Unknown profile 'nope'", 5, 0, 0, ".cache", true},
+		{"notPresent", "logs", "Unknown profile 'logs'", defaultPrintLimit, 0, 0, "", false},
+		{"badprofile", "logs", "Invalid profile '[logs'", 5, 0, 0, "", false},
+		{"dupeprofile", "", "Duplicate profile 'logs'", defaultPrintLimit, 0, 0, "", false},
+		{"duplicate", "logs", "Duplicate option", defaultPrintLimit, 0, 0, "", false},
+	}
+
+	for ix, tc := range testCases {
+		cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+			func() (string, error) { return "testdata/" + tc.configFile, nil })
+		cfg.profile = tc.profile
+		err := cfg.loadDefaults()
+		switch {
+		case err == nil && len(tc.error) > 0:
+			t.Error(ix, "Expected Error for", tc.configFile, tc.profile)
+		case err != nil && len(tc.error) == 0:
+			t.Error(ix, "Unexpected error", err)
+		case err != nil && !strings.Contains(err.Error(), tc.error):
+			t.Errorf("%d Expected error to contain '%s', but got '%s'\n", ix, tc.error, err)
+		}
+		if err != nil && strings.HasPrefix(tc.error, "Unknown") != errors.Is(err, errUnknownProfile) {
+			t.Error(ix, "Unknown profile error should be errUnknownProfile", err)
+		}
+		if len(tc.ibases) == 0 {
+			continue // Values are whatever was loaded prior to the error
+		}
+		if cfg.maxCount.v != tc.count || cfg.maxDepth.v != tc.depth || cfg.maxAge.seconds != tc.age ||
+			cfg.ignoreBases.v != tc.ibases || cfg.printDirname.v != tc.pdirname {
+			t.Error(ix, "Unexpected values", cfg.maxCount.v, cfg.maxDepth.v, cfg.maxAge.seconds,
+				cfg.ignoreBases.v, cfg.printDirname.v)
+		}
+	}
+}
+
+func TestSelectedProfile(t *testing.T) {
+	testCases := []struct {
+		args []string
+		exp  string
+	}{
+		{[]string{}, ""},
+		{[]string{"-profile", "logs", "/var/log"}, "logs"},
+		{[]string{"--profile=logs", "-age", "1h"}, "logs"},
+		{[]string{"-age", "1h", "-profile", "logs"}, "logs"},
+		{[]string{"/var/log", "-profile", "logs"}, ""}, // Paths end the options
+		{[]string{"-exec", "-profile", "/var/log"}, ""},
+	}
+	for ix, tc := range testCases {
+		got := selectedProfile(tc.args)
+		if got != tc.exp {
+			t.Errorf("%d selectedProfile(%v) got '%s', expected '%s'", ix, tc.args, got, tc.exp)
+		}
+	}
+}
+
+func TestConfigLoadEnv(t *testing.T) {
+	testCases := []struct {
+		env    map[string]string
+		args   []string
+		error  string
+		count  uint
+		ibases string
+		pstats bool
+	}{
+		{map[string]string{}, nil, "", 123, "ignoreBases", true},
+		{map[string]string{"FAD_COUNT": "7", "FAD_IBASES": "+.git", "FAD_PSTATS": "false"}, nil, "",
+			7, "ignoreBases,.git", false},
+		{map[string]string{"FAD_COUNT": "7", "FAD_IBASES": ".git"}, []string{"-count", "9", "-ibases", "+x"}, "",
+			9, ".git,x", true},
+		{map[string]string{"FAD_count": "7", "FAD_HELP": "true", "FADCOUNT": "7"}, nil, "", 123, "ignoreBases", true},
+		{map[string]string{"FAD_PSTATS": ""}, nil, "FAD_PSTATS: invalid syntax", 123, "ignoreBases", true},
+		{map[string]string{"FAD_AGE": "1x", "FAD_COUNT": "7"}, nil, "FAD_AGE: Age '1x' has invalid unit",
+			7, "ignoreBases", true}, // Valid variables are still applied
+		{map[string]string{"FAD_AGE": "1x", "FAD_COUNT": "x", "FAD_IBASES": "+.git"}, nil,
+			"FAD_AGE: Age '1x' has invalid unit|FAD_COUNT: invalid syntax", 123, "ignoreBases,.git", true},
+	}
+
+	for ix, tc := range testCases {
+		cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+			func() (string, error) { return "testdata/loadall", nil })
+		err := cfg.loadDefaults()
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs := cfg.loadEnv(func(name string) (string, bool) {
+			v, ok := tc.env[name]
+			return v, ok
+		})
+		var expected []string // Each error is contained in the corresponding entry
+		if len(tc.error) > 0 {
+			expected = strings.Split(tc.error, "|")
+		}
+		if len(errs) != len(expected) {
+			t.Error(ix, "Expected errors", expected, "got", errs)
+		} else {
+			for ex, err := range errs {
+				if !strings.Contains(err.Error(), expected[ex]) {
+					t.Errorf("%d Expected error to contain '%s', but got '%s'\n", ix, expected[ex], err)
+				}
+			}
+		}
+		cfg.setFlags()
+		err = cfg.flagSet.Parse(tc.args)
+		if err != nil {
+			t.Fatal(ix, err)
+		}
+		if cfg.maxCount.v != tc.count || cfg.ignoreBases.v != tc.ibases || cfg.printStats.v != tc.pstats {
+			t.Error(ix, "Unexpected values", cfg.maxCount.v, cfg.ignoreBases.v, cfg.printStats.v)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Placeholders replaced in -exec and -exec+ commands
+const (
+	execDir   = "{dir}"   // Directory as printed by -pdirname
+	execEntry = "{entry}" // Most recently modified entry as normally printed
+)
+
+// execArgMax limits the total size of the arguments of each -exec+ invocation. It is well
+// below the ARG_MAX of any modern system so there is no need to determine the actual
+// limit at run-time.
+const execArgMax = 128 * 1024
+
+// splitExecCommand splits a -exec command into words in the manner of the shell, but
+// without any expansions other than quote removal. Words are separated by whitespace.
+// Single quotes preserve everything up to the closing quote, double quotes preserve
+// everything except for backslash escapes of '"' and '\', and a backslash outside of
+// quotes preserves the next character.
+//
+// Since no shell is involved, placeholders are replaced after splitting so that paths
+// containing spaces or quotes are always passed as a single argument.
+func splitExecCommand(s string) (words []string, err error) {
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	switch {
+	case quote != 0:
+		return nil, fmt.Errorf("-exec command has an unterminated %c quote", quote)
+	case escaped:
+		return nil, errors.New("-exec command ends with a backslash")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	if len(words) == 0 {
+		return nil, errors.New("-exec command is empty")
+	}
+
+	return
+}
+
+// expandExecWord replaces the placeholders in word with the values of the candidate.
+func expandExecWord(word string, c *candidate) string {
+	return strings.NewReplacer(execDir, c.dirName(), execEntry, filepath.Clean(c.path)).Replace(word)
+}
+
+// hasExecPlaceholder returns true if word contains a placeholder.
+func hasExecPlaceholder(word string) bool {
+	return strings.Contains(word, execDir) || strings.Contains(word, execEntry)
+}
+
+// execEach runs the command once for each candidate with the placeholders replaced.
+// Failures are reported to stderr and counted. Commands inherit stdout and stderr.
+func (scn *scanner) execEach(words []string, stdout, stderr io.Writer) (failures int) {
+	for _, c := range scn.allCandidates.cf {
+		args := make([]string, 0, len(words))
+		for _, word := range words {
+			args = append(args, expandExecWord(word, c))
+		}
+		if !runExec(args, stdout, stderr) {
+			failures++
+		}
+	}
+
+	return
+}
+
+// execBatch runs the command with every word containing a placeholder repeated for each
+// candidate, as with the "+" form of find -exec. If the arguments would exceed
+// execArgMax, the candidates are spread across as many invocations as needed.
+func (scn *scanner) execBatch(words []string, stdout, stderr io.Writer) (failures int) {
+	var fixed int
+	for _, word := range words {
+		if !hasExecPlaceholder(word) {
+			fixed += len(word) + 1
+		}
+	}
+
+	cf := scn.allCandidates.cf
+	for len(cf) > 0 {
+		size := fixed
+		var n int
+		for n = 0; n < len(cf); n++ {
+			for _, word := range words {
+				if hasExecPlaceholder(word) {
+					size += len(expandExecWord(word, cf[n])) + 1
+				}
+			}
+			if size > execArgMax && n > 0 {
+				break
+			}
+		}
+
+		var args []string
+		for _, word := range words {
+			if !hasExecPlaceholder(word) {
+				args = append(args, word)
+				continue
+			}
+			for _, c := range cf[:n] {
+				args = append(args, expandExecWord(word, c))
+			}
+		}
+		if !runExec(args, stdout, stderr) {
+			failures++
+		}
+		cf = cf[n:]
+	}
+
+	return
+}
+
+// runExec runs the command and reports any failure, including a non-zero exit status,
+// to stderr along with the quoted command line.
+func runExec(args []string, stdout, stderr io.Writer) bool {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if err != nil {
+		quoted := make([]string, 0, len(args))
+		for _, arg := range args {
+			quoted = append(quoted, shQuote(arg))
+		}
+		fmt.Fprintf(stderr, "Error: Exec %s: %s\n", strings.Join(quoted, " "), err)
+		return false
+	}
+
+	return true
+}
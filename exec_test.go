@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitExecCommand(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want []string
+		err  string
+	}{
+		{"git -C {dir} status -s", []string{"git", "-C", "{dir}", "status", "-s"}, ""},
+		{"  a\t b  ", []string{"a", "b"}, ""},
+		{`a 'b c' "d e"`, []string{"a", "b c", "d e"}, ""},
+		{`a 'it''s' "say \"hi\" \\ \x"`, []string{"a", "its", `say "hi" \ x`}, ""},
+		{`a b\ c \'`, []string{"a", "b c", "'"}, ""},
+		{`a '' ""`, []string{"a", "", ""}, ""},
+		{"rsync -a {dir}/ host:{dir}", []string{"rsync", "-a", "{dir}/", "host:{dir}"}, ""},
+		{"", nil, "empty"},
+		{"   ", nil, "empty"},
+		{"a 'b", nil, "unterminated ' quote"},
+		{`a "b`, nil, `unterminated " quote`},
+		{`a b\`, nil, "backslash"},
+	}
+
+	for ix, tc := range testCases {
+		got, err := splitExecCommand(tc.in)
+		if len(tc.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Error(ix, "Expected error", tc.err, "got", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(ix, "Unexpected error", err)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") || len(got) != len(tc.want) {
+			t.Errorf("%d got %q want %q", ix, got, tc.want)
+		}
+	}
+}
+
+// testExecScanner returns a scanner with candidates at paths in ascending age order.
+func testExecScanner(t *testing.T, paths ...string) *scanner {
+	var stderr bytes.Buffer
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+		func() (string, error) { return "", nil })
+	scn, _, err := testScannerSetup(cfg, &stderr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ix, p := range paths {
+		c := &candidate{deleted: strings.HasSuffix(p, "/gone")}
+		c.set(p, 0, scn.baseTime, scn.baseTime.Add(-time.Duration(ix)*time.Hour))
+		scn.allCandidates.addMaybe(c)
+	}
+	scn.allCandidates.sortAscending()
+
+	return scn
+}
+
+func TestExec(t *testing.T) {
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip(err)
+	}
+	scn := testExecScanner(t, "/a b/f1", "c/f2", "d/gone")
+
+	var stdout, stderr bytes.Buffer
+	failures := scn.execEach([]string{"echo", "[{dir}]", "{entry}"}, &stdout, &stderr)
+	want := "[/a b] /a b/f1\n[c] c/f2\n[d/gone] d/gone\n"
+	if failures != 0 || stdout.String() != want {
+		t.Errorf("execEach failures %d got %q want %q %s", failures, stdout.String(), want, stderr.String())
+	}
+
+	stdout.Reset()
+	failures = scn.execBatch([]string{"echo", "-n", "{dir}", "--"}, &stdout, &stderr)
+	if failures != 0 || stdout.String() != "/a b c d/gone --" {
+		t.Errorf("execBatch failures %d got %q", failures, stdout.String())
+	}
+
+	// Failures are reported with the quoted command line
+	stdout.Reset()
+	stderr.Reset()
+	failures = scn.execEach([]string{"false", "{dir}"}, &stdout, &stderr)
+	if failures != 3 || !strings.Contains(stderr.String(), "Error: Exec 'false' '/a b': exit status 1") {
+		t.Error("Expected three failures", failures, stderr.String())
+	}
+	stderr.Reset()
+	failures = scn.execBatch([]string{"/no/such/command"}, &stdout, &stderr)
+	if failures != 1 || !strings.Contains(stderr.String(), "/no/such/command") {
+		t.Error("Expected missing command failure", failures, stderr.String())
+	}
+}
+
+func TestExecBatchSplit(t *testing.T) {
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip(err)
+	}
+	long := strings.Repeat("x", execArgMax*2/5)
+	scn := testExecScanner(t, long+"/1", long+"/2", long+"/3")
+
+	var stdout, stderr bytes.Buffer
+	failures := scn.execBatch([]string{"echo", "{entry}"}, &stdout, &stderr)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if failures != 0 || len(lines) != 2 {
+		t.Error("Expected two invocations", failures, len(lines), stderr.String())
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// extCounts tallies the extensions of the entries modified within the age window of a
+// single directory. It is populated during the scan of the directory so it is never
+// accessed concurrently.
+type extCounts map[string]int
+
+// add counts the extension of name, if it has one. Names without an extension and
+// dot-files such as ".profile" are not counted.
+func (ec extCounts) add(name string) {
+	ext := filepath.Ext(name)
+	if len(ext) <= 1 || len(ext) == len(name) {
+		return
+	}
+	ec[ext[1:]]++
+}
+
+// String returns the tallies in descending count order with ties in extension order, as
+// in "go:12 md:2 sum:1".
+func (ec extCounts) String() string {
+	exts := make([]string, 0, len(ec))
+	for ext := range ec {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if ec[exts[i]] != ec[exts[j]] {
+			return ec[exts[i]] > ec[exts[j]]
+		}
+		return exts[i] < exts[j]
+	})
+
+	var sb strings.Builder
+	for ix, ext := range exts {
+		if ix > 0 {
+			sb.WriteByte(' ')
+		}
+		fmt.Fprintf(&sb, "%s:%d", ext, ec[ext])
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestExtCounts(t *testing.T) {
+	ec := make(extCounts)
+	if got := ec.String(); got != "" {
+		t.Error("Empty extCounts should be an empty string, not", got)
+	}
+
+	for _, name := range []string{"main.go", "scanner.go", "README.md", "go.sum",
+		"Makefile", ".profile", "trailing.", "config.go"} {
+		ec.add(name)
+	}
+	exp := "go:3 md:1 sum:1"
+	if got := ec.String(); got != exp {
+		t.Errorf("Expected '%s', got '%s'\n", exp, got)
+	}
+}
+
+// Test that only extensions within the age window are tallied.
+func TestExtScanner(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+		func() (string, error) { return "", nil })
+	cfg.maxAge.seconds = 120
+	cfg.printExts.v = true
+	scn, can, err := testScannerSetup(cfg, &stderr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	td := testDir{}
+	for _, tc := range []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"a.go", -1 * time.Minute},
+		{"b.go", -1 * time.Minute},
+		{"c.md", -2 * time.Minute},
+		{"d.txt", -3 * time.Hour}, // Too old to be counted
+	} {
+		tde := &testDirEntry{fileInfo: &testFileInfo{name: tc.name, modTime: now.Add(tc.offset)}}
+		td.dirents = append(td.dirents, tde)
+	}
+	scn.scan(td.tree("."), 0, ".")
+	scn.wait()
+
+	if len(can.cf) != 1 {
+		t.Fatal("Expected one candidate got", len(can.cf))
+	}
+	exp := "go:2 md:1"
+	if can.cf[0].exts != exp {
+		t.Errorf("Expected exts of '%s', got '%s'\n", exp, can.cf[0].exts)
+	}
+}
@@ -82,3 +82,28 @@ func (csf *commaStringFlag) String() string { return csf.v }
 
 // Age
 type ageFlag = age
+
+// Optional string. The flag package treats it as a bool so that it can be given without a
+// value, in which case it takes the default value. An explicit value can only be given
+// with "=", as in -flag=value, and "false" disables the flag.
+type optionalFlag struct {
+	v        string
+	defaultV string
+}
+
+func (of *optionalFlag) Set(s string) error {
+	switch s {
+	case "true":
+		of.v = of.defaultV
+	case "false":
+		of.v = ""
+	default:
+		of.v = s
+	}
+
+	return nil
+}
+
+func (of *optionalFlag) String() string { return of.v }
+
+func (of *optionalFlag) IsBoolFlag() bool { return true }
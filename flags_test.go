@@ -9,6 +9,7 @@ func TestFlagsSet(t *testing.T) {
 	var bf boolFlag
 	var ui uintFlag
 	var cs commaStringFlag
+	of := optionalFlag{defaultV: "dflt"}
 
 	testCases := []struct {
 		fv     flagValue
@@ -30,6 +31,10 @@ func TestFlagsSet(t *testing.T) {
 		{&cs, "e,f", "", "e,f"},
 		{&cs, "", "", ""},        // Clear
 		{&cs, "+c,d", "", "c,d"}, // Append to empty
+
+		{&of, "true", "", "dflt"}, // Flag without a value
+		{&of, "name", "", "name"},
+		{&of, "false", "", ""},
 	}
 
 	for ix, tc := range testCases {
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// readScanList reads the list of paths to scan from the named file, or from stdin if the
+// name is "-". Paths are newline terminated unless nul is true, in which case they are
+// NUL terminated as produced by "find -print0". Empty paths are ignored.
+//
+// The main purpose of reading paths from a file is to avoid the system argument length
+// limit when scanning large numbers of paths.
+func readScanList(name string, nul bool, stdin io.Reader) ([]string, error) {
+	in := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var paths []string
+	scanner := bufio.NewScanner(in)
+	if nul {
+		scanner.Split(scanNul)
+	}
+	for scanner.Scan() {
+		if len(scanner.Text()) > 0 {
+			paths = append(paths, scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Reading %s: %w", name, err)
+	}
+
+	return paths, nil
+}
+
+// scanNul is a bufio.SplitFunc which returns NUL terminated tokens. It is modelled on
+// bufio.ScanLines.
+func scanNul(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[0:i], nil
+	}
+	if atEOF { // Final, non-terminated token
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil // Request more data
+}
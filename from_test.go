@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFromReadScanList(t *testing.T) {
+	testCases := []struct {
+		input  string
+		nul    bool
+		expect []string
+	}{
+		{"", false, nil},
+		{"a\nb c\n\nd", false, []string{"a", "b c", "d"}},
+		{"a\r\nb\r\n", false, []string{"a", "b"}},
+		{"a\x00b\nc\x00\x00d", true, []string{"a", "b\nc", "d"}},
+		{"a\x00", true, []string{"a"}},
+	}
+
+	for ix, tc := range testCases {
+		got, err := readScanList("-", tc.nul, strings.NewReader(tc.input))
+		if err != nil {
+			t.Error(ix, "Unexpected error", err)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(tc.expect, "|") || len(got) != len(tc.expect) {
+			t.Errorf("%d Expected %q, got %q\n", ix, tc.expect, got)
+		}
+	}
+}
+
+func TestFromFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "list")
+	err := os.WriteFile(name, []byte("/etc\n/var/log\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readScanList(name, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "/etc" || got[1] != "/var/log" {
+		t.Error("Unexpected list from file", got)
+	}
+
+	_, err = readScanList(name+".noexist", false, nil)
+	if err == nil {
+		t.Error("Expected error from non-existent file")
+	}
+}
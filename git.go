@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitCommitMarker starts each commit header in the "git log" output parsed by
+// readGitLog. A path which starts with this control character is possible, but only in
+// a repository which deserves to be misreported.
+const gitCommitMarker = "\x01"
+
+// loadGit constructs a memFS of the files tracked by git in the work tree at or below
+// dir. The modification time of each file is the commit time of the most recent commit
+// which touched it, so activity reflects history rather than the time of the checkout.
+// Files with uncommitted changes, whether staged or not, files which have never been
+// committed, and untracked files which are not ignored by git, use their file system
+// modification time as they are, by definition, current activity.
+//
+// Everything is read from the local repository. Nothing is fetched.
+func loadGit(dir string) (fs.FS, error) {
+	tracked, err := gitTrackedFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	times, err := gitCommitTimes(dir, tracked)
+	if err != nil {
+		return nil, err
+	}
+	dirty, err := runGit(dir, "ls-files", "-z", "--modified", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	staged, err := runGit(dir, "diff", "--cached", "--name-only", "-z", "--no-renames", "--relative")
+	if err != nil {
+		return nil, err
+	}
+	current := append(splitNul(dirty), splitNul(staged)...)
+	for name := range tracked {
+		if _, ok := times[name]; !ok { // Only in the index
+			current = append(current, name)
+		}
+	}
+
+	mfs := newMemFS()
+	for name, mode := range tracked {
+		if err := mfs.add(name, mode, times[name], statDetails{}); err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+	}
+	for _, name := range current {
+		fi, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil { // Deleted from the work tree but not yet committed
+			continue
+		}
+		if err := mfs.add(name, fi.Mode(), fi.ModTime(), getStatDetails(fi)); err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+	}
+
+	return mfs, nil
+}
+
+// gitTrackedFiles returns the modes of all files in the index at or below dir. Submodules
+// are presented as empty directories.
+func gitTrackedFiles(dir string) (map[string]fs.FileMode, error) {
+	out, err := runGit(dir, "ls-files", "-z", "--stage")
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := make(map[string]fs.FileMode)
+	for _, line := range splitNul(out) { // "100644 <object> <stage>\t<path>"
+		info, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("%s: git ls-files: unexpected output '%s'", dir, line)
+		}
+		var mode fs.FileMode
+		switch strings.Fields(info)[0] {
+		case "120000":
+			mode = fs.ModeSymlink | 0777
+		case "160000":
+			mode = fs.ModeDir | 0755
+		case "100755":
+			mode = 0755
+		default:
+			mode = 0644
+		}
+		tracked[name] = mode
+	}
+
+	return tracked, nil
+}
+
+// gitCommitTimes returns the most recent commit time of each path at or below dir by
+// walking the history from HEAD. The walk stops once all tracked paths have been seen as
+// older history cannot change the result. Paths never seen, such as files which are only
+// in the index, are absent from the returned map.
+func gitCommitTimes(dir string, tracked map[string]fs.FileMode) (map[string]time.Time, error) {
+	cmd := gitCommand(dir, "log", "--format=tformat:"+gitCommitMarker+"%ct", "--name-only",
+		"-z", "--no-renames", "--relative", "--", ".")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("%s: git: %w", dir, err)
+	}
+
+	times, logErr := readGitLog(out, tracked)
+	if logErr == nil && len(times) == len(tracked) {
+		cmd.Process.Kill() // No need to walk the rest of history
+		cmd.Wait()
+		return times, nil
+	}
+	io.Copy(io.Discard, out)
+	err = cmd.Wait()
+	if logErr != nil {
+		return nil, fmt.Errorf("%s: %w", dir, logErr)
+	}
+	if err != nil {
+		// A repository without any commits is not an error, merely lacking history
+		if strings.Contains(stderr.String(), "does not have any commits") {
+			return times, nil
+		}
+		return nil, fmt.Errorf("%s: git log: %w %s", dir, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return times, nil
+}
+
+// readGitLog parses the output of "git log -z --name-only" using the gitCommitMarker
+// format. Each commit is a NUL terminated header of the marker and the commit time
+// followed by NUL terminated paths, the first of which starts with a newline. Paths which
+// are no longer tracked are of no interest. Reading stops once all tracked paths have
+// been seen.
+func readGitLog(r io.Reader, tracked map[string]fs.FileMode) (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+	var commitTime time.Time
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	scanner.Split(scanNul)
+	for len(times) < len(tracked) && scanner.Scan() {
+		token := scanner.Text()
+		if strings.HasPrefix(token, gitCommitMarker) {
+			secs, err := strconv.ParseInt(strings.TrimPrefix(token, gitCommitMarker), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("git log: invalid commit time '%s'", token)
+			}
+			commitTime = time.Unix(secs, 0)
+			continue
+		}
+		name := strings.TrimPrefix(token, "\n")
+		if len(name) == 0 {
+			continue
+		}
+		if _, ok := tracked[name]; !ok {
+			continue
+		}
+		if _, ok := times[name]; !ok { // Newest commit comes first
+			times[name] = commitTime
+		}
+	}
+
+	return times, scanner.Err()
+}
+
+// runGit runs a git command in dir and returns its standard output.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := gitCommand(dir, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: git %s: %w %s", dir, args[0], err,
+			strings.TrimSpace(stderr.String()))
+	}
+
+	return string(out), nil
+}
+
+func gitCommand(dir string, args ...string) *exec.Cmd {
+	return exec.Command("git", append([]string{"-C", dir, "-c", "core.quotepath=off"}, args...)...)
+}
+
+// splitNul splits NUL terminated output into its tokens.
+func splitNul(s string) []string {
+	s = strings.TrimSuffix(s, "\x00")
+	if len(s) == 0 {
+		return nil
+	}
+
+	return strings.Split(s, "\x00")
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testGitRepo creates a repository in a temporary directory with one commit per entry
+// of commits. All work tree files are then touched to now, as a fresh clone would be.
+func testGitRepo(t *testing.T, now time.Time, commits []struct {
+	name   string
+	offset time.Duration
+}) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git command not available")
+	}
+	dir := t.TempDir()
+	git := func(env []string, args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_SYSTEM=/dev/null",
+			"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.net",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.net")
+		cmd.Env = append(cmd.Env, env...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(args, err, string(out))
+		}
+	}
+
+	git(nil, "init", "-q")
+	for _, c := range commits {
+		path := filepath.Join(dir, filepath.FromSlash(c.name))
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, []byte(c.offset.String()), 0600); err != nil {
+			t.Fatal(err)
+		}
+		date := "GIT_COMMITTER_DATE=" + now.Add(c.offset).Format(time.RFC3339)
+		git([]string{date}, "add", c.name)
+		git([]string{date}, "commit", "-q", "-m", c.name)
+	}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			os.Chtimes(path, now, now)
+		}
+		return nil
+	})
+
+	return dir
+}
+
+func TestGitLoad(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	dir := testGitRepo(t, now, []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"docs/README.md", -5 * time.Hour},
+		{"src/main.go", -4 * time.Hour},
+		{"docs/README.md", -3 * time.Hour}, // Most recent commit wins
+		{".gitignore", -6 * time.Hour},
+	})
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.o\n"), 0600)
+	os.Chtimes(filepath.Join(dir, ".gitignore"), now.Add(-6*time.Hour), now.Add(-6*time.Hour))
+	untracked := filepath.Join(dir, "src", "new.go")
+	os.WriteFile(untracked, nil, 0600)
+	os.Chtimes(untracked, now.Add(-time.Minute), now.Add(-time.Minute))
+	os.WriteFile(filepath.Join(dir, "src", "main.o"), nil, 0600) // Ignored by git
+
+	fsys, err := loadGit(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		modTime time.Time
+	}{
+		{"docs/README.md", now.Add(-3 * time.Hour)},
+		{"src/main.go", now.Add(-4 * time.Hour)},
+		{"src/new.go", now.Add(-time.Minute)},
+		{"src", now.Add(-time.Minute)}, // Implied directory
+	}
+	for ix, tc := range testCases {
+		fi, err := fs.Stat(fsys, tc.name)
+		if err != nil {
+			t.Error(ix, err)
+			continue
+		}
+		if !fi.ModTime().Equal(tc.modTime) {
+			t.Error(ix, tc.name, "Expected", tc.modTime, "got", fi.ModTime())
+		}
+	}
+	if _, err := fs.Stat(fsys, "src/main.o"); err == nil {
+		t.Error("Ignored file should not be present")
+	}
+
+	// A subdirectory only sees its own files with paths relative to itself
+	fsys, err = loadGit(filepath.Join(dir, "docs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	des, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(des) != 1 || des[0].Name() != "README.md" {
+		t.Error("Expected just README.md, got", des)
+	}
+}
+
+// Test that staged changes and files which have never been committed use their file
+// system time rather than the time of their last commit, if any.
+func TestGitStaged(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	dir := testGitRepo(t, now, []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"o/old", -5 * 365 * 24 * time.Hour},
+		{"k/kept", -4 * time.Hour},
+	})
+	for _, name := range []string{"o/old", "a/added"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0700)
+		os.WriteFile(path, []byte("staged"), 0600)
+		os.Chtimes(path, now.Add(-time.Minute), now.Add(-time.Minute))
+		cmd := exec.Command("git", "-C", dir, "add", name)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(err, string(out))
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	ec := realMain(now, []string{"-git", "-age", "99Y", dir},
+		func() (string, error) { return "", nil }, noEnv, nil, &stdout, &stderr)
+	if ec != EX_OK {
+		t.Fatal("Unexpected exit code", ec, stderr.String())
+	}
+	for _, exp := range []string{"1m:f:" + filepath.Join(dir, "o", "old") + "\n",
+		"1m:f:" + filepath.Join(dir, "a", "added") + "\n", "4h:f:" + filepath.Join(dir, "k", "kept") + "\n"} {
+		if !strings.Contains(stdout.String(), exp) {
+			t.Errorf("Expected %q in\n%s%s", exp, stdout.String(), stderr.String())
+		}
+	}
+}
+
+// Test that -git ranks by commit time when every file was touched by the checkout
+func TestGitScan(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	dir := testGitRepo(t, now, []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"old/a.go", -5 * time.Hour},
+		{"young/b.go", -1 * time.Hour},
+		{"middle/c.go", -3 * time.Hour},
+	})
+
+	var stdout, stderr strings.Builder
+	ec := realMain(now, []string{"-git", "-count", "2", dir},
+		func() (string, error) { return "", nil }, noEnv, nil, &stdout, &stderr)
+	if ec != EX_OK {
+		t.Fatal("Unexpected exit code", ec, stderr.String())
+	}
+	exp := "1h:f:" + filepath.Join(dir, "young", "b.go") + "\n" +
+		"3h:f:" + filepath.Join(dir, "middle", "c.go") + "\n"
+	if stdout.String() != exp {
+		t.Errorf("Expected\n%sGot\n%s%s", exp, stdout.String(), stderr.String())
+	}
+}
+
+func TestGitErrors(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git command not available")
+	}
+	dir := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir)) // Don't find an outer repo
+
+	var stdout, stderr strings.Builder
+	ec := realMain(time.Now(), []string{"-git", dir},
+		func() (string, error) { return "", nil }, noEnv, nil, &stdout, &stderr)
+	if ec != EX_OSFILE {
+		t.Error("Expected EX_OSFILE, got", ec)
+	}
+	if !strings.Contains(stderr.String(), "Error: Loading Git Repository "+dir) {
+		t.Error("Unexpected stderr", stderr.String())
+	}
+}
+
+func TestGitReadLog(t *testing.T) {
+	log := "\x01300\x00\nsrc/a.go\x00gone.go\x00" +
+		"\x01200\x00\nsrc/b.go\x00src/a.go\x00" +
+		"\x01100\x00\nsrc/c.go\x00"
+	tracked := map[string]fs.FileMode{"src/a.go": 0, "src/b.go": 0}
+
+	times, err := readGitLog(strings.NewReader(log), tracked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 2 || times["src/a.go"].Unix() != 300 || times["src/b.go"].Unix() != 200 {
+		t.Error("Unexpected times", times)
+	}
+
+	_, err = readGitLog(strings.NewReader("\x01yesterday\x00"), tracked)
+	if err == nil || !strings.Contains(err.Error(), "invalid commit time") {
+		t.Error("Expected invalid commit time error, got", err)
+	}
+}
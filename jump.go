@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	visitsFile       = "visits" // Within the fad state directory
+	visitsMaxRank    = 10000    // Total rank beyond which all ranks are aged
+	visitsAgeFactor  = 0.9      // Applied to all ranks when visitsMaxRank is exceeded
+	visitsMinRank    = 1        // Aged ranks below this are forgotten
+	jumpActivityRank = 1        // Activity counts as this many visits when it occurred
+
+	visitsLockSuffix = ".lock"               // Appended to the database path
+	visitsLockWait   = 5 * time.Second       // Give up waiting for the lock after this
+	visitsLockStale  = 30 * time.Second      // A lock older than this was abandoned
+	visitsLockPoll   = 10 * time.Millisecond // Interval between attempts to take the lock
+)
+
+// visit is the frecency record of a directory in the visits database. rank increases by
+// one for each visit and decays as the database ages.
+type visit struct {
+	rank float64
+	last time.Time
+}
+
+// visits is the database of directories visited by the user as recorded by -visit. It is
+// stored as lines of "rank<tab>unix-time<tab>path" which is easily inspected and edited.
+type visits map[string]*visit
+
+// visitsPath returns the path of the visits database.
+func visitsPath() (string, error) {
+	dir, err := userStateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, Name, visitsFile), nil
+}
+
+// loadVisits reads the visits database. A missing database is not an error, it merely
+// means that no visits have been recorded.
+func loadVisits(path string) (visits, error) {
+	vs := make(visits)
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return vs, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	err = vs.read(path, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return vs, nil
+}
+
+func (vs visits) read(name string, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			return fmt.Errorf("%s:%d: expected rank, time and path", name, lineNo)
+		}
+		rank, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return fmt.Errorf("%s:%d: rank %w", name, lineNo, strconvTrimError(err))
+		}
+		secs, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s:%d: time %w", name, lineNo, strconvTrimError(err))
+		}
+		vs[fields[2]] = &visit{rank: rank, last: time.Unix(secs, 0)}
+	}
+
+	return scanner.Err()
+}
+
+// save writes the database via a temporary file so that readers never see a partial
+// database. Writers must hold the lock, see lockVisits.
+func (vs visits) save(path string) error {
+	paths := make([]string, 0, len(vs))
+	for p := range vs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var buf bytes.Buffer
+	for _, p := range paths {
+		fmt.Fprintf(&buf, "%s\t%d\t%s\n",
+			strconv.FormatFloat(vs[p].rank, 'f', -1, 64), vs[p].last.Unix(), p)
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// record notes a visit to dir. Once the total rank exceeds visitsMaxRank all ranks are
+// aged so that directories which are no longer visited are eventually forgotten.
+func (vs visits) record(dir string, now time.Time) {
+	v, ok := vs[dir]
+	if !ok {
+		v = &visit{}
+		vs[dir] = v
+	}
+	v.rank++
+	v.last = now
+
+	var total float64
+	for _, v := range vs {
+		total += v.rank
+	}
+	if total <= visitsMaxRank {
+		return
+	}
+	for p, v := range vs {
+		v.rank *= visitsAgeFactor
+		if v.rank < visitsMinRank {
+			delete(vs, p)
+		}
+	}
+}
+
+// frecency weights rank by how recently then occurred relative to now.
+func frecency(rank float64, then, now time.Time) float64 {
+	switch since := now.Sub(then); {
+	case since < time.Hour:
+		return rank * 4
+	case since < 24*time.Hour:
+		return rank * 2
+	case since < 7*24*time.Hour:
+		return rank / 2
+	}
+
+	return rank / 4
+}
+
+// jump returns the directory which best matches query with directories ranked by the
+// frecency of both visits and the activity found by the scan. Activity within a directory
+// counts as jumpActivityRank visits at the time of the activity. Visited directories which
+// no longer exist are ignored as are directories within archives and other virtual trees.
+//
+// Returns false if no directory matches.
+func (scn *scanner) jump(query string, vs visits, now time.Time) (string, bool) {
+	scores := make(map[string]float64)
+	for dir, c := range scn.activity {
+		sDir, ok := scn.scanned[dir]
+		if !ok || !sDir.tree.live {
+			continue
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		scores[abs] += frecency(jumpActivityRank, c.modTime, now)
+	}
+	for dir, v := range vs {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			scores[dir] += frecency(v.rank, v.last, now)
+		}
+	}
+
+	terms := strings.Fields(strings.ToLower(query))
+	for _, match := range []func([]string, string) bool{jumpMatch, jumpFuzzyMatch} {
+		var best string
+		var bestScore float64
+		for dir, score := range scores {
+			if !match(terms, dir) {
+				continue
+			}
+			if len(best) == 0 || score > bestScore ||
+				score == bestScore && (len(dir) < len(best) || len(dir) == len(best) && dir < best) {
+				best, bestScore = dir, score
+			}
+		}
+		if len(best) > 0 {
+			return best, true
+		}
+	}
+
+	return "", false
+}
+
+// jumpMatch returns true if all terms appear in dir in order, ignoring case, with the last
+// term appearing in the final component of dir. Thus "src fad" matches "/home/me/src/fad"
+// but not "/home/me/fad/src".
+func jumpMatch(terms []string, dir string) bool {
+	if len(terms) == 0 {
+		return false
+	}
+	lower := strings.ToLower(dir)
+	last := len(terms) - 1
+	for _, term := range terms[:last] {
+		ix := strings.Index(lower, term)
+		if ix == -1 {
+			return false
+		}
+		lower = lower[ix+len(term):]
+	}
+	ix := strings.LastIndex(lower, terms[last])
+
+	return ix != -1 && !strings.ContainsRune(lower[ix+len(terms[last]):], filepath.Separator)
+}
+
+// jumpFuzzyMatch is the fallback if nothing matches with jumpMatch. It returns true if
+// the characters of the terms appear in order, though not necessarily adjacent, in the
+// final component of dir. Thus "fdr" matches "/home/me/src/fad-release".
+func jumpFuzzyMatch(terms []string, dir string) bool {
+	chars := []rune(strings.Join(terms, ""))
+	if len(chars) == 0 {
+		return false
+	}
+	for _, r := range strings.ToLower(filepath.Base(dir)) {
+		if r == chars[0] {
+			chars = chars[1:]
+			if len(chars) == 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// lockVisits serializes updates of the database at path, as made when multiple shells
+// record visits concurrently, with a lock file created exclusively alongside the
+// database. A lock which outlives visitsLockStale was abandoned by a process which died
+// and is removed. Returns a function which releases the lock.
+func lockVisits(path string) (func(), error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+	lock := path + visitsLockSuffix
+	deadline := time.Now().Add(visitsLockWait)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) > visitsLockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s: timed out waiting for lock", lock)
+		}
+		time.Sleep(visitsLockPoll)
+	}
+}
+
+// updateVisits applies update to the database at path while holding the lock so that
+// concurrent updates are never lost.
+func updateVisits(path string, update func(visits)) error {
+	unlock, err := lockVisits(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	vs, err := loadVisits(path)
+	if err != nil {
+		return err
+	}
+	update(vs)
+
+	return vs.save(path)
+}
+
+// recordVisit adds a visit to dir to the visits database. dir is made absolute so that
+// visits are independent of the current directory of the shell. A dir containing a
+// newline is rejected as it cannot be represented in the line-based database.
+func recordVisit(dir string, now time.Time) error {
+	if strings.Contains(dir, "\n") {
+		return fmt.Errorf("%q: newlines are not supported in visited directories", dir)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	path, err := visitsPath()
+	if err != nil {
+		return err
+	}
+
+	return updateVisits(path, func(vs visits) { vs.record(abs, now) })
+}
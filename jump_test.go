@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVisits(t *testing.T) {
+	testStateDir(t)
+	path, err := visitsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := loadVisits(path)
+	if err != nil || len(vs) != 0 {
+		t.Fatal("Missing database should be empty", vs, err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	vs.record("/a", now)
+	vs.record("/a", now)
+	vs.record("/b c", now.Add(time.Hour))
+	err = vs.save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "2\t1700000000\t/a\n1\t1700003600\t/b c\n" {
+		t.Error("Unexpected database", string(data))
+	}
+
+	vs, err = loadVisits(path)
+	if err != nil || len(vs) != 2 || vs["/a"].rank != 2 || !vs["/b c"].last.Equal(now.Add(time.Hour)) {
+		t.Error("Round trip failed", vs, err)
+	}
+
+	// A newline would corrupt the database so such directories are never recorded
+	err = recordVisit("/a\nb", now)
+	if err == nil || !strings.Contains(err.Error(), "newlines are not supported") {
+		t.Error("Expected newline error, got", err)
+	}
+	vs, err = loadVisits(path)
+	if err != nil || len(vs) != 2 {
+		t.Error("Database should be unchanged", vs, err)
+	}
+
+	// Exceeding the maximum ages everything and forgets the rarely visited
+	vs["/a"].rank = visitsMaxRank
+	vs.record("/d", now)
+	if _, ok := vs["/b c"]; ok {
+		t.Error("/b c should have been forgotten", vs)
+	}
+	if vs["/a"].rank != visitsMaxRank*visitsAgeFactor {
+		t.Error("/a should have been aged", vs["/a"].rank)
+	}
+
+	testCases := []struct {
+		data string
+		err  string
+	}{
+		{"1\t2\n", "expected rank"},
+		{"x\t2\t/a\n", "rank"},
+		{"1\tx\t/a\n", "time"},
+		{"\n1.5\t2\t/a\tb\n", ""}, // Tabs are allowed in paths
+	}
+	for ix, tc := range testCases {
+		vs := make(visits)
+		err := vs.read("test", strings.NewReader(tc.data))
+		if len(tc.err) == 0 {
+			if err != nil || vs["/a\tb"] == nil || vs["/a\tb"].rank != 1.5 {
+				t.Error(ix, "Unexpected", err, vs)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Error(ix, "Expected error with", tc.err, "got", err)
+		}
+	}
+}
+
+func TestVisitsConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), visitsFile)
+	now := time.Unix(1700000000, 0)
+	const writers = 20
+	errs := make(chan error, writers)
+	for ix := 0; ix < writers; ix++ {
+		go func() {
+			errs <- updateVisits(path, func(vs visits) { vs.record(fmt.Sprint("/d", ix), now) })
+		}()
+	}
+	for ix := 0; ix < writers; ix++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	vs, err := loadVisits(path)
+	if err != nil || len(vs) != writers {
+		t.Error("Concurrent visits were lost", len(vs), err)
+	}
+	if _, err := os.Stat(path + visitsLockSuffix); !os.IsNotExist(err) {
+		t.Error("Lock should have been released", err)
+	}
+
+	// A stale lock is taken over rather than waited for
+	err = os.WriteFile(path+visitsLockSuffix, nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * visitsLockStale)
+	os.Chtimes(path+visitsLockSuffix, stale, stale)
+	err = updateVisits(path, func(vs visits) { vs.record("/stale", now) })
+	if err != nil {
+		t.Error("Stale lock should be removed", err)
+	}
+}
+
+func TestJumpMatch(t *testing.T) {
+	sep := string(filepath.Separator)
+	dir := sep + filepath.Join("home", "me", "src", "fad-release")
+	testCases := []struct {
+		query string
+		exact bool
+		fuzzy bool
+	}{
+		{"", false, false},
+		{"fad", true, true},
+		{"FAD", true, true},
+		{"src fad", true, false},
+		{"fad src", false, false},
+		{"me", false, false}, // Not in the last component
+		{"release", true, true},
+		{"fdr", false, true},
+		{"f d r", true, true},
+		{"rdf", false, false},
+	}
+
+	for ix, tc := range testCases {
+		terms := strings.Fields(strings.ToLower(tc.query))
+		if got := jumpMatch(terms, dir); got != tc.exact {
+			t.Error(ix, tc.query, "jumpMatch got", got)
+		}
+		if got := jumpFuzzyMatch(terms, dir); got != tc.fuzzy {
+			t.Error(ix, tc.query, "jumpFuzzyMatch got", got)
+		}
+	}
+
+	if !jumpMatch([]string{"fad"}, filepath.Join(sep, "fad", "src", "fad")) {
+		t.Error("Last term should match the last occurrence")
+	}
+}
+
+func TestFrecency(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		since time.Duration
+		want  float64
+	}{
+		{time.Minute, 40}, {2 * time.Hour, 20}, {2 * 24 * time.Hour, 5}, {30 * 24 * time.Hour, 2.5},
+	}
+	for ix, tc := range testCases {
+		if got := frecency(10, now.Add(-tc.since), now); got != tc.want {
+			t.Error(ix, "Got", got, "want", tc.want)
+		}
+	}
+}
+
+func TestJump(t *testing.T) {
+	testStateDir(t)
+	root := t.TempDir()
+	now := time.Now()
+	for _, d := range []string{"work/app", "play/app", "play/game"} {
+		os.MkdirAll(filepath.Join(root, d), 0700)
+	}
+	old := now.Add(-30 * 24 * time.Hour)
+	for _, f := range []string{"work/app/old", "play/game/old"} {
+		os.WriteFile(filepath.Join(root, f), nil, 0600)
+		os.Chtimes(filepath.Join(root, f), old, old)
+	}
+	fresh := filepath.Join(root, "play", "app", "new")
+	os.WriteFile(fresh, nil, 0600)
+	noConfig := func() (string, error) { return "", nil }
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr strings.Builder
+		ec := realMain(now, args, noConfig, noEnv, nil, &stdout, &stderr)
+		return ec, stdout.String(), stderr.String()
+	}
+
+	// Activity alone prefers the recently modified app
+	ec, out, errs := run("-jump", "app", root)
+	if ec != EX_OK || out != filepath.Join(root, "play", "app")+"\n" {
+		t.Error("Activity jump", ec, out, errs)
+	}
+
+	// Frequent visits outweigh activity
+	for ix := 0; ix < 3; ix++ {
+		if ec, _, errs := run("-visit", filepath.Join(root, "work", "app")); ec != EX_OK {
+			t.Fatal("Visit failed", ec, errs)
+		}
+	}
+	ec, out, errs = run("-jump", "app", root)
+	if ec != EX_OK || out != filepath.Join(root, "work", "app")+"\n" {
+		t.Error("Visit jump", ec, out, errs)
+	}
+
+	// Fuzzy fallback and no match
+	ec, out, _ = run("-jump", "gm", root)
+	if ec != EX_OK || out != filepath.Join(root, "play", "game")+"\n" {
+		t.Error("Fuzzy jump", ec, out)
+	}
+	ec, out, errs = run("-jump", "nothing", root)
+	if ec != EX_NOINPUT || len(out) > 0 || !strings.Contains(errs, "No directory matches") {
+		t.Error("No match", ec, out, errs)
+	}
+}
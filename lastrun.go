@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLastRunName = "default"
+	lastRunDir         = "last-run" // Within the fad state directory
+)
+
+// lastRunNameRE constrains names so that they are safe to use as file names.
+var lastRunNameRE = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]*$`)
+
+// lastRunPath returns the path of the file which records the start time of the last run
+// with the given name.
+func lastRunPath(name string) (string, error) {
+	if !lastRunNameRE.MatchString(name) {
+		return "", fmt.Errorf("-since-last-run name '%s' must only contain letters, digits, '.', '_' or '-'",
+			name)
+	}
+	dir, err := userStateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, Name, lastRunDir, name), nil
+}
+
+// loadLastRun returns the start time recorded by a previous run. A missing file is not an
+// error, it merely means there was no previous run, in which case false is returned.
+func loadLastRun(path string) (time.Time, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return t, true, nil
+}
+
+// saveLastRun records the start time of this run for the next run. The write is via a
+// temporary file so that a failed write never leaves a corrupt record.
+func saveLastRun(path string, start time.Time) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(tmp, start.Format(time.RFC3339Nano))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// sinceAge returns the age of last relative to start for use as -age. Since an age of
+// zero means unlimited, the result is at least one second.
+func sinceAge(start, last time.Time) age {
+	secs := max(int64(start.Sub(last).Seconds()), 1)
+
+	return age{seconds: secs, multiplier: second}
+}
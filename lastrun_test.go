@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStateDir points the user state directory at a temporary directory on all systems.
+func testStateDir(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+
+	return dir
+}
+
+func TestLastRun(t *testing.T) {
+	testStateDir(t)
+	dir := t.TempDir()
+	now := time.Now()
+	old := filepath.Join(dir, "old")
+	os.WriteFile(old, nil, 0600)
+	os.Chtimes(old, now.Add(-time.Hour), now.Add(-time.Hour))
+	noConfig := func() (string, error) { return "", nil }
+
+	// First run has nothing to go on so reports everything
+	var stdout, stderr strings.Builder
+	ec := realMain(now, []string{"-since-last-run", dir}, noConfig, noEnv, nil, &stdout, &stderr)
+	if ec != EX_OK || stdout.String() != "1h:f:"+old+"\n" {
+		t.Fatal("First run", ec, stdout.String(), stderr.String())
+	}
+
+	// Second run only reports activity since the first run
+	young := filepath.Join(dir, "young")
+	os.WriteFile(young, nil, 0600)
+	os.Chtimes(young, now.Add(5*time.Minute), now.Add(5*time.Minute))
+	stdout.Reset()
+	ec = realMain(now.Add(10*time.Minute), []string{"-since-last-run", dir}, noConfig, noEnv, nil,
+		&stdout, &stderr)
+	if ec != EX_OK || stdout.String() != "5m:f:"+young+"\n" {
+		t.Error("Second run", ec, stdout.String(), stderr.String())
+	}
+
+	// Third run, immediately after, finds nothing
+	stdout.Reset()
+	ec = realMain(now.Add(10*time.Minute+time.Millisecond), []string{"-since-last-run", dir},
+		noConfig, noEnv, nil, &stdout, &stderr)
+	if ec != EX_OK || stdout.Len() != 0 {
+		t.Error("Third run", ec, stdout.String(), stderr.String())
+	}
+
+	// A different name has its own record so starts afresh
+	stdout.Reset()
+	ec = realMain(now.Add(time.Hour), []string{"-since-last-run=weekly", dir}, noConfig, noEnv, nil,
+		&stdout, &stderr)
+	if ec != EX_OK || stdout.String() != "55m:f:"+young+"\n" {
+		t.Error("Named run", ec, stdout.String(), stderr.String())
+	}
+}
+
+func TestLastRunErrors(t *testing.T) {
+	state := testStateDir(t)
+	noConfig := func() (string, error) { return "", nil }
+
+	var stdout, stderr strings.Builder
+	ec := realMain(time.Now(), []string{"-since-last-run=../escape", "testdata/maxdir"}, noConfig, noEnv, nil,
+		&stdout, &stderr)
+	if ec != EX_USAGE || !strings.Contains(stderr.String(), "must only contain") {
+		t.Error("Expected EX_USAGE, got", ec, stderr.String())
+	}
+
+	path, err := lastRunPath("corrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(path, state) {
+		t.Error("Last run path", path, "not in state dir", state)
+	}
+	os.MkdirAll(filepath.Dir(path), 0700)
+	os.WriteFile(path, []byte("yesterday\n"), 0600)
+	stderr.Reset()
+	ec = realMain(time.Now(), []string{"-since-last-run=corrupt", "testdata/maxdir"}, noConfig, noEnv, nil,
+		&stdout, &stderr)
+	if ec != EX_NOINPUT || !strings.Contains(stderr.String(), "Error: Loading Last Run") {
+		t.Error("Expected EX_NOINPUT, got", ec, stderr.String())
+	}
+}
+
+func TestLastRunSinceAge(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		last   time.Time
+		expect int64
+	}{
+		{now.Add(-time.Hour), hour},
+		{now, 1},                // Zero would mean unlimited
+		{now.Add(time.Hour), 1}, // Clock went backwards
+		{now.Add(-1500 * time.Millisecond), 1},
+	}
+
+	for ix, tc := range testCases {
+		a := sinceAge(now, tc.last)
+		if a.seconds != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "got", a.seconds)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Listings are the text output of find or ls captured on another system. They are loaded
+// as a memFS so that the normal scan, candidate selection and printing apply. Two formats
+// are recognized based on the first non-empty line:
+//
+//	find ... -printf '%T@ %y %p\n'
+//	ls -lR --time-style=full-iso ...
+//
+// The virtual tree is rooted at the longest directory common to all listed paths, which
+// is normally the starting point given to find or ls, so that -depth applies as if the
+// scan had been run on the original system.
+
+var (
+	listingFindRE = regexp.MustCompile(`^(-?\d+)(\.\d+)? ([bcdpflsDU]) (.+)$`)
+	listingLsRE   = regexp.MustCompile(`^([-bcdlpsD])[-rwxsStT]{9}\S*\s+\d+\s+\S+\s+\S+\s+(?:\d+,\s*)?\d+\s+` +
+		`(\d{4}-\d\d-\d\d \d\d:\d\d:\d\d(?:\.\d+)? [-+]\d{4}) (.+)$`)
+)
+
+// listingTypes maps the find %y and ls type characters to file modes.
+var listingTypes = map[byte]fs.FileMode{
+	'-': 0,
+	'f': 0,
+	'd': fs.ModeDir,
+	'l': fs.ModeSymlink,
+	'b': fs.ModeDevice,
+	'c': fs.ModeDevice | fs.ModeCharDevice,
+	'p': fs.ModeNamedPipe,
+	's': fs.ModeSocket,
+	'D': fs.ModeIrregular, // Solaris door
+	'U': fs.ModeIrregular, // find's "unknown"
+}
+
+type listingEntry struct {
+	name    string // Cleaned slash-separated path as listed
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// readListingFile reads the named listing file, or stdin if name is "-", and returns the
+// virtual tree and the path of its root for display purposes.
+func readListingFile(name string, stdin io.Reader) (fs.FS, string, error) {
+	in := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	return readListing(name, in)
+}
+
+// readListing parses a find or ls listing into a memFS rooted at the longest common
+// directory of all entries, which is also returned.
+func readListing(name string, r io.Reader) (fs.FS, string, error) {
+	var entries []listingEntry
+	var parseLine func(string) (*listingEntry, error)
+	var lsDir string // Current directory of an ls -lR listing
+
+	lineNumber := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		if parseLine == nil { // First line determines the format
+			if listingFindRE.MatchString(line) {
+				parseLine = parseFindLine
+			} else {
+				parseLine = func(line string) (*listingEntry, error) {
+					return parseLsLine(line, &lsDir)
+				}
+			}
+		}
+
+		e, err := parseLine(line)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s:%d: %w", name, lineNumber, err)
+		}
+		if e != nil {
+			entries = append(entries, *e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("Reading %s: %w", name, err)
+	}
+	if len(entries) == 0 {
+		return nil, "", fmt.Errorf("%s: no entries found in listing", name)
+	}
+
+	root, err := listingRoot(entries)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	mfs := newMemFS()
+	for _, e := range entries {
+		rel := strings.TrimPrefix(strings.TrimPrefix(e.name, root), "/")
+		if root == "." {
+			rel = e.name
+		}
+		err := mfs.add(rel, e.mode, e.modTime, statDetails{})
+		if errors.Is(err, fs.ErrInvalid) { // Unrepresentable name - just skip
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return mfs, filepath.FromSlash(root), nil
+}
+
+// parseFindLine parses a line of find -printf '%T@ %y %p\n' output. Note that %T@ is
+// a decimal fraction of seconds.
+func parseFindLine(line string) (*listingEntry, error) {
+	m := listingFindRE.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("not in find -printf '%%T@ %%y %%p' format: '%s'", line)
+	}
+	secs, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time '%s'", m[1])
+	}
+	var nsecs int64
+	if len(m[2]) > 0 {
+		frac := (strings.TrimPrefix(m[2], ".") + "000000000")[:9]
+		nsecs, _ = strconv.ParseInt(frac, 10, 64) // Cannot fail as RE guarantees digits
+	}
+
+	return &listingEntry{name: path.Clean(m[4]), mode: listingTypes[m[3][0]],
+		modTime: time.Unix(secs, nsecs)}, nil
+}
+
+// parseLsLine parses a line of ls -lR --time-style=full-iso output. Directory headers
+// update dir, which names the directory of subsequent entries. Headers, "total" lines
+// and the "." and ".." entries of ls -a return a nil entry.
+//
+// Names are taken literally so ls must not be asked to quote them.
+func parseLsLine(line string, dir *string) (*listingEntry, error) {
+	if strings.HasSuffix(line, ":") && !listingLsRE.MatchString(line) {
+		*dir = path.Clean(strings.TrimSuffix(line, ":"))
+		return nil, nil
+	}
+	if strings.HasPrefix(line, "total ") {
+		return nil, nil
+	}
+
+	m := listingLsRE.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("not in ls -l --time-style=full-iso format: '%s'", line)
+	}
+	if len(*dir) == 0 {
+		return nil, errors.New("ls -lR entry precedes the first directory header")
+	}
+	modTime, err := time.Parse("2006-01-02 15:04:05.999999999 -0700", m[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid time '%s'", m[2])
+	}
+
+	mode := listingTypes[m[1][0]]
+	base := m[3]
+	if mode&fs.ModeSymlink != 0 {
+		base, _, _ = strings.Cut(base, " -> ")
+	}
+	if base == "." || base == ".." {
+		return nil, nil
+	}
+
+	return &listingEntry{name: path.Join(*dir, base), mode: mode, modTime: modTime}, nil
+}
+
+// listingRoot returns the longest directory common to all entries. An entry which is a
+// directory is a candidate root in its own right, which is how the starting point of
+// find becomes the root.
+func listingRoot(entries []listingEntry) (string, error) {
+	root := entries[0].name
+	if !entries[0].mode.IsDir() {
+		root = path.Dir(root)
+	}
+	for _, e := range entries[1:] {
+		for !listingContains(root, e.name) {
+			if root == "/" || root == "." {
+				return "", errors.New("listing mixes absolute and relative paths")
+			}
+			root = path.Dir(root)
+		}
+	}
+
+	return root, nil
+}
+
+// listingContains returns true if name is root or is below root.
+func listingContains(root, name string) bool {
+	switch root {
+	case ".":
+		return !path.IsAbs(name)
+	case "/":
+		return path.IsAbs(name)
+	}
+
+	return name == root || strings.HasPrefix(name, root+"/")
+}
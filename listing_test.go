@@ -0,0 +1,166 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListingFind(t *testing.T) {
+	listing := `1700000000.0000000000 d /home/user/proj
+1700000100.2500000000 f /home/user/proj/main.go
+1700000050.0000000000 d /home/user/proj/docs
+1700000060.1234567890 f /home/user/proj/docs/file with spaces.md
+1700000070.0000000000 l /home/user/proj/link
+1700000080 p /home/user/proj/fifo
+`
+	fsys, root, err := readListing("find.txt", strings.NewReader(listing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != filepath.FromSlash("/home/user/proj") {
+		t.Error("Wrong root", root)
+	}
+
+	testCases := []struct {
+		name    string
+		mode    fs.FileMode
+		modTime time.Time
+	}{
+		{"main.go", 0, time.Unix(1700000100, 250000000)},
+		{"docs", fs.ModeDir, time.Unix(1700000050, 0)},
+		{"docs/file with spaces.md", 0, time.Unix(1700000060, 123456789)},
+		{"link", fs.ModeSymlink, time.Unix(1700000070, 0)},
+		{"fifo", fs.ModeNamedPipe, time.Unix(1700000080, 0)},
+	}
+	testListingEntries(t, fsys, testCases)
+}
+
+func TestListingLs(t *testing.T) {
+	listing := `etc:
+total 12
+drwxr-xr-x  2 root root  4096 2024-01-02 03:04:05.123456789 +0000 ssl
+-rw-r--r--. 1 root wheel  123 2024-01-02 04:04:05.000000000 +0100 hosts
+lrwxrwxrwx  1 root root     7 2024-01-02 05:00:00.000000000 +0000 localtime -> /usr/share/zoneinfo/UTC
+crw-rw-rw-  1 root root  1, 3 2024-01-02 06:00:00.000000000 +0000 null
+drwxr-xr-x  2 root root  4096 2024-01-01 00:00:00.000000000 +0000 .
+drwxr-xr-x  2 root root  4096 2024-01-01 00:00:00.000000000 +0000 ..
+
+etc/ssl:
+total 4
+-rw-------  1 root root   100 2024-01-03 00:00:00.000000000 +0000 key.pem:
+`
+	fsys, root, err := readListing("ls.txt", strings.NewReader(listing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != "etc" {
+		t.Error("Wrong root", root)
+	}
+
+	utc := func(s string) time.Time {
+		tm, _ := time.Parse(time.RFC3339Nano, s)
+		return tm
+	}
+	testCases := []struct {
+		name    string
+		mode    fs.FileMode
+		modTime time.Time
+	}{
+		{"ssl", fs.ModeDir, utc("2024-01-02T03:04:05.123456789Z")},
+		{"hosts", 0, utc("2024-01-02T03:04:05Z")},
+		{"localtime", fs.ModeSymlink, utc("2024-01-02T05:00:00Z")},
+		{"null", fs.ModeDevice | fs.ModeCharDevice, utc("2024-01-02T06:00:00Z")},
+		{"ssl/key.pem:", 0, utc("2024-01-03T00:00:00Z")},
+	}
+	testListingEntries(t, fsys, testCases)
+}
+
+func testListingEntries(t *testing.T, fsys fs.FS, testCases []struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+}) {
+	t.Helper()
+	for ix, tc := range testCases {
+		fi, err := fs.Stat(fsys, tc.name)
+		if err != nil {
+			t.Error(ix, err)
+			continue
+		}
+		if fi.Mode().Type() != tc.mode {
+			t.Error(ix, tc.name, "Mode expected", tc.mode, "got", fi.Mode())
+		}
+		if !fi.ModTime().Equal(tc.modTime) {
+			t.Error(ix, tc.name, "ModTime expected", tc.modTime, "got", fi.ModTime())
+		}
+	}
+}
+
+func TestListingRoot(t *testing.T) {
+	testCases := []struct {
+		names []string
+		root  string
+		err   string
+	}{
+		{[]string{"/a/b/c", "/a/b/d"}, "/a/b", ""},
+		{[]string{"/a/b", "/a/bc"}, "/a", ""}, // Not fooled by common string prefix
+		{[]string{"/x", "/y"}, "/", ""},
+		{[]string{".", "a", "b/c"}, ".", ""},
+		{[]string{"proj/a", "proj/b"}, "proj", ""},
+		{[]string{"/a", "b"}, "", "mixes absolute and relative"},
+	}
+
+	for ix, tc := range testCases {
+		var entries []listingEntry
+		for _, n := range tc.names {
+			entries = append(entries, listingEntry{name: n})
+		}
+		root, err := listingRoot(entries)
+		if err != nil {
+			if len(tc.err) == 0 || !strings.Contains(err.Error(), tc.err) {
+				t.Error(ix, "Unexpected error", err)
+			}
+			continue
+		}
+		if len(tc.err) > 0 {
+			t.Error(ix, "Expected error", tc.err)
+			continue
+		}
+		if root != tc.root {
+			t.Error(ix, "Expected", tc.root, "got", root)
+		}
+	}
+}
+
+func TestListingErrors(t *testing.T) {
+	testCases := []struct {
+		listing string
+		err     string
+	}{
+		{"", "no entries found"},
+		{"1700000000 f /a\nrubbish\n", "x:2: not in find -printf"},
+		{"-rw-r--r-- 1 root root 1 2024-01-01 00:00:00 +0000 orphan\n", "x:1: ls -lR entry precedes"},
+		{"d:\n-rw-r--r-- 1 root root 1 2024-01-01 00:00:00 hosts\n", "x:2: not in ls"},
+		{"1700000000 f /a\n1700000000 f b\n", "mixes absolute"},
+	}
+
+	for ix, tc := range testCases {
+		_, _, err := readListing("x", strings.NewReader(tc.listing))
+		if err == nil {
+			t.Error(ix, "Expected error", tc.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%d Wrong error returned. Want '%s' got '%s'\n", ix, tc.err, err.Error())
+		}
+	}
+
+	_, _, err := readListingFile("testdata/noexist", nil)
+	if !os.IsNotExist(err) {
+		t.Error("Expected not exist error, got", err)
+	}
+}
@@ -1,26 +1,42 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	iofs "io/fs"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 )
 
 func main() {
-	os.Exit(realMain(time.Now(), os.Args[1:], os.UserConfigDir, os.Stdout, os.Stderr))
+	os.Exit(realMain(time.Now(), os.Args[1:], os.UserConfigDir, os.LookupEnv, os.Stdin, os.Stdout, os.Stderr))
 }
 
 // realMain does all the work and can more easily be the target of testing
-func realMain(start time.Time, args []string, confFunc userConfigDirFunc, stdout, stderr io.Writer) int {
+func realMain(start time.Time, args []string, confFunc userConfigDirFunc, lookupEnv envLookupFunc,
+	stdin io.Reader, stdout, stderr io.Writer) int {
 	fs := flag.NewFlagSet(Name, flag.ContinueOnError)
 	fs.SetOutput(stderr)
 	fs.Usage = func() { fmt.Fprintln(stderr, "Consider -h for option details") }
 	cfg := newConfig(fs, confFunc)
+	cfg.profile = selectedProfile(args)
 	err := cfg.loadDefaults() // Load early so setFlags see revised defaults
-	if err != nil {           // Config load errors only generate warnings
+	var profileErr error      // An unknown profile is only fatal if scanning
+	if errors.Is(err, errUnknownProfile) {
+		profileErr = err
+	} else if err != nil { // Config load errors only generate warnings
+		fmt.Fprintln(stderr, "Warning:", err)
+	}
+	for _, err := range cfg.loadEnv(lookupEnv) { // Environment overrides the config file
 		fmt.Fprintln(stderr, "Warning:", err)
 	}
 
@@ -46,7 +62,20 @@ func realMain(start time.Time, args []string, confFunc userConfigDirFunc, stdout
 		return EX_OK
 	}
 
+	if len(cfg.shellInit) > 0 {
+		err = printShellInit(stdout, cfg.shellInit, fs)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return EX_USAGE
+		}
+		return EX_OK
+	}
+
 	// We're actually going to run a scan
+	if profileErr != nil {
+		fmt.Fprintln(stderr, "Error:", profileErr)
+		return EX_CONFIG
+	}
 	err = cfg.compile()
 	if err != nil {
 		fmt.Fprintln(stderr, err)
@@ -54,29 +83,274 @@ func realMain(start time.Time, args []string, confFunc userConfigDirFunc, stdout
 	}
 
 	scanList := fs.Args()
-	if len(scanList) == 0 { // If none supplied, scan current working directory.
+	if cfg.fromFile == "-" && cfg.listingFile == "-" {
+		fmt.Fprintln(stderr, "Error: -from and -listing cannot both read stdin")
+		return EX_USAGE
+	}
+	if !slices.Contains(formatNames, cfg.format) {
+		fmt.Fprintf(stderr, "Error: -format '%s' must be one of %s\n", cfg.format, strings.Join(formatNames, ", "))
+		return EX_USAGE
+	}
+	if cfg.flagGiven("interval") && cfg.interval <= 0 {
+		fmt.Fprintf(stderr, "Error: -interval '%s' must be greater than zero\n", cfg.interval)
+		return EX_USAGE
+	}
+	if cfg.rescanInterval < 0 {
+		fmt.Fprintf(stderr, "Error: -rescan '%s' must not be negative\n", cfg.rescanInterval)
+		return EX_USAGE
+	}
+	expect := cfg.expectActive.seconds > 0 || cfg.expectQuiet.seconds > 0
+	if !expect && cfg.flagGiven("expect-depth") { // Harmless if only set in defaults.conf
+		fmt.Fprintln(stderr, "Error: -expect-depth is only meaningful with -expect-active or -expect-quiet")
+		return EX_USAGE
+	}
+	expectName := "expect-active" // Either or both may be given
+	if cfg.expectActive.seconds == 0 {
+		expectName = "expect-quiet"
+	}
+	var modes []string // Options which replace the printed list
+	for name, set := range map[string]bool{"exec": len(cfg.execCmd) > 0, "exec+": len(cfg.execBatch) > 0,
+		expectName: expect, "format": cfg.format != formatText, "interval": cfg.interval > 0,
+		"jump": len(cfg.jumpQuery) > 0, "serve": len(cfg.serveAddr) > 0, "tui": cfg.tui, "watch": cfg.watch} {
+		if set {
+			modes = append(modes, "-"+name)
+		}
+	}
+	if len(modes) > 1 {
+		sort.Strings(modes)
+		fmt.Fprintln(stderr, "Error:", strings.Join(modes, " and "), "cannot be used together")
+		return EX_USAGE
+	}
+
+	var execWords []string
+	if cmd := cfg.execCmd + cfg.execBatch; len(cmd) > 0 { // At most one is set
+		execWords, err = splitExecCommand(cmd)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return EX_USAGE
+		}
+	}
+
+	if len(cfg.visitDir) > 0 { // Recording a visit does not involve a scan
+		err = recordVisit(cfg.visitDir, start)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error: Recording Visit", err)
+			return EX_IOERR
+		}
+		return EX_OK
+	}
+
+	var listingFS iofs.FS
+	var listingRoot string
+	if len(cfg.listingFile) > 0 {
+		listingFS, listingRoot, err = readListingFile(cfg.listingFile, stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return EX_NOINPUT
+		}
+	}
+	if len(cfg.fromFile) > 0 {
+		fromList, err := readScanList(cfg.fromFile, cfg.fromNul, stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return EX_NOINPUT
+		}
+		scanList = append(scanList, fromList...)
+	} else if cfg.fromNul {
+		fmt.Fprintln(stderr, "Error: -0 is only meaningful with -from")
+		return EX_USAGE
+	} else if len(scanList) == 0 && listingFS == nil { // If none supplied, scan cwd
 		scanList = append(scanList, ".")
 	}
 
-	allCandidates := newCandidates(int(cfg.maxCount.v), cfg.maxAge)
-	cc := newConcurrencyController(int(cfg.maxScanners.v))
-	scn := newScanner(cfg, cc, allCandidates, start, stderr)
-	for _, dirName := range scanList {
-		dirName = filepath.Clean(dirName) // Clean here so we can avoid .Join/Clean later
-		scn.descend(0, dirName)           // Runs a goroutine
+	for ix, dirName := range scanList {
+		scanList[ix] = filepath.Clean(dirName) // Clean here so we can avoid .Join/Clean later
 	}
-	scn.wait() // Wait for all goroutines started by scn.descend()
 
-	// Sort and print
-	scn.allCandidates.sortAscending()
+	if len(cfg.serveAddr) > 0 { // Each request is scanned with its own config
+		serveConfig := func(query url.Values) (*config, error) {
+			sfs := flag.NewFlagSet(Name, flag.ContinueOnError)
+			sfs.SetOutput(io.Discard)
+			c := newConfig(sfs, confFunc)
+			c.profile = cfg.profile
+			c.loadDefaults() // Any warnings were given at startup
+			c.loadEnv(lookupEnv)
+			c.setFlags()
+			err := sfs.Parse(args)
+			if err == nil {
+				err = c.setQuery(query)
+			}
+			if err == nil {
+				err = c.compile()
+			}
+			return c, err
+		}
+		scan := func(c *config, stderr io.Writer) *scanner {
+			return scanAll(c, time.Now(), scanList, listingFS, listingRoot, stderr)
+		}
+		stop := make(chan struct{})
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			close(stop)
+		}()
+		err := newServer(serveConfig, scan, cfg.rescanInterval).listenAndServe(cfg.serveAddr, stop)
+		signal.Stop(sigs)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return EX_UNAVAILABLE
+		}
+		return EX_OK
+	}
+
+	// Read the earlier snapshot before scanning as it may be overwritten by -save
+	var previous *snapshot
+	if len(cfg.diffFile) > 0 {
+		previous, err = loadSnapshot(cfg.diffFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return EX_NOINPUT
+		}
+	}
+
+	var jumpVisits visits
+	if len(cfg.jumpQuery) > 0 {
+		var visitsDB string
+		visitsDB, err = visitsPath()
+		if err == nil {
+			jumpVisits, err = loadVisits(visitsDB)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "Error: Loading Visits", err)
+			return EX_NOINPUT
+		}
+	}
+
+	// A previous run, if any, replaces -age with the time since that run
+	var lastRun string
+	if len(cfg.sinceLastRun.v) > 0 {
+		lastRun, err = lastRunPath(cfg.sinceLastRun.v)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return EX_USAGE
+		}
+		last, ok, err := loadLastRun(lastRun)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error: Loading Last Run", err)
+			return EX_NOINPUT
+		}
+		if ok {
+			cfg.maxAge = sinceAge(start, last)
+		}
+	}
+
+	scn := scanAll(cfg, start, scanList, listingFS, listingRoot, stderr)
 	end := time.Now()
 	secs := end.Sub(start)
 
-	scn.printCandidates(stdout)
-	if scn.cfg.printStats.v {
+	if len(cfg.saveFile) > 0 {
+		err = scn.newSnapshot().save(cfg.saveFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error: Saving Snapshot", err)
+			return EX_IOERR
+		}
+	}
+
+	if len(lastRun) > 0 {
+		err = saveLastRun(lastRun, start)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error: Saving Last Run", err)
+			return EX_IOERR
+		}
+	}
+
+	if cfg.watch { // Watch takes over all printing until interrupted
+		stop := make(chan struct{})
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			close(stop)
+		}()
+		err := scn.watch(stdout, isTerminal(stdout), stop)
+		signal.Stop(sigs)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return EX_UNAVAILABLE
+		}
+		return EX_OK
+	}
+
+	if cfg.interval > 0 { // The changes of each scan replace printing until interrupted
+		stop := make(chan struct{})
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			close(stop)
+		}()
+		scan := func(now time.Time) *scanner {
+			return scanAll(cfg, now, scanList, listingFS, listingRoot, stderr)
+		}
+		scn.poll(stdout, cfg.interval, scan, stop)
+		signal.Stop(sigs)
+		return EX_OK
+	}
+
+	if len(cfg.jumpQuery) > 0 {
+		dir, ok := scn.jump(cfg.jumpQuery, jumpVisits, end)
+		if !ok {
+			fmt.Fprintf(stderr, "Error: No directory matches '%s'\n", cfg.jumpQuery)
+			return EX_NOINPUT
+		}
+		fmt.Fprintln(stdout, dir)
+		return EX_OK
+	}
+
+	if cfg.tui { // The browser replaces the printed list
+		rescan := func(stderr io.Writer) *scanner {
+			return scanAll(cfg, time.Now(), scanList, listingFS, listingRoot, stderr)
+		}
+		selected, err := browse(scn, rescan)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return EX_UNAVAILABLE
+		}
+		if len(selected) > 0 {
+			fmt.Fprintln(stdout, selected)
+		}
+		return EX_OK
+	}
+
+	if expect { // The summary replaces the printed list as does the exit code
+		return scn.check(stdout, cfg.expectDepth.v, cfg.expectActive, cfg.expectQuiet)
+	}
+
+	var execFailures int
+	switch {
+	case len(cfg.execCmd) > 0:
+		execFailures = scn.execEach(execWords, stdout, stderr)
+	case len(cfg.execBatch) > 0:
+		execFailures = scn.execBatch(execWords, stdout, stderr)
+	case cfg.format == formatPrometheus: // Metrics include the stats
+		scn.printPrometheus(stdout, secs)
+	case previous != nil:
+		scn.printDiff(stdout, scn.diff(previous))
+	default:
+		scn.printCandidates(stdout)
+	}
+	if scn.cfg.printFuture.v && cfg.format == formatText {
+		scn.printFuture(stdout)
+	}
+	if scn.cfg.printStats.v && cfg.format == formatText {
 		scn.printStats(stdout, secs)
 	}
 
+	if execFailures > 0 {
+		return EX_UNAVAILABLE
+	}
+
 	// If any access errors occurred, exit non-zero
 	if scn.stats.errorCount > 0 {
 		return EX_OSFILE
@@ -84,3 +358,29 @@ func realMain(start time.Time, args []string, confFunc userConfigDirFunc, stdout
 
 	return EX_OK
 }
+
+// scanAll scans every path in scanList plus the listing, if any, and returns the scanner
+// with its candidates sorted ready for printing.
+func scanAll(cfg *config, start time.Time, scanList []string, listingFS iofs.FS, listingRoot string,
+	stderr io.Writer) *scanner {
+	allCandidates := newCandidates(int(cfg.maxCount.v), cfg.maxAge)
+	cc := newConcurrencyController(int(cfg.maxScanners.v))
+	scn := newScanner(cfg, cc, allCandidates, start, stderr)
+	if cfg.watch || len(cfg.saveFile) > 0 || len(cfg.diffFile) > 0 || len(cfg.jumpQuery) > 0 ||
+		cfg.format == formatPrometheus || cfg.expectActive.seconds > 0 || cfg.expectQuiet.seconds > 0 {
+		scn.trackActivity()
+	}
+	scn.rootPaths = scn.setRoots(scanList)
+	for _, dirName := range scn.rootPaths {
+		scn.descendRoot(dirName) // Runs a goroutine
+	}
+	if listingFS != nil {
+		scn.descendFS(listingFS, listingRoot)
+	}
+	scn.wait() // Wait for all goroutines started by scn.descend()
+
+	scn.allCandidates.sortAscending()
+	scn.futureCandidates.sortAscending()
+
+	return scn
+}
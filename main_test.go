@@ -7,30 +7,70 @@ import (
 	"time"
 )
 
+// noEnv is the environment lookup for tests so that they are not affected by any FAD_*
+// variables set by whoever runs them.
+func noEnv(string) (string, bool) { return "", false }
+
 func TestMainOptions(t *testing.T) {
 	testCases := []struct {
 		options  []string
 		config   string
+		stdin    string
 		excode   int
 		out, err string // Contained in error text
 	}{
-		{[]string{"--unknown"}, "", EX_USAGE, "", "Consider -h for option"},
-		{[]string{""}, "testdata/main1", EX_OK, "main1", "at testdata"}, // Bad config
-		{[]string{"-v"}, "", EX_OK, "Version:", ""},
-		{[]string{"-h"}, "", EX_OK, "SYNOPSIS", ""},
-		{[]string{"--manpage"}, "", EX_OK, ".Nm fad", ""},
-		{[]string{"--count", "-1"}, "", EX_USAGE, "", "invalid value"},
-		{[]string{}, "", EX_OK, ":f:", ""}, // scanList default to "."
-		{[]string{"-pstats"}, "", EX_OK, "Elapse:", ""},
-		{[]string{"/dev/null"}, "", EX_OSFILE, "", "/dev/null is not a directory"},
-		{[]string{"--iregexes", `aa\`}, "", EX_USAGE, "", "does not compile"},
+		{[]string{"--unknown"}, "", "", EX_USAGE, "", "Consider -h for option"},
+		{[]string{""}, "testdata/main1", "", EX_OK, "main1", "at testdata"}, // Bad config
+		{[]string{"-v"}, "", "", EX_OK, "Version:", ""},
+		{[]string{"-h"}, "", "", EX_OK, "SYNOPSIS", ""},
+		{[]string{"--manpage"}, "", "", EX_OK, ".Nm fad", ""},
+		{[]string{"--count", "-1"}, "", "", EX_USAGE, "", "invalid value"},
+		{[]string{}, "", "", EX_OK, ":f:", ""}, // scanList default to "."
+		{[]string{"-pstats"}, "", "", EX_OK, "Elapse:", ""},
+		{[]string{"/dev/null"}, "", "", EX_OSFILE, "", "/dev/null is not a directory"},
+		{[]string{"--iregexes", `aa\`}, "", "", EX_USAGE, "", "does not compile"},
+		{[]string{"-from", "-"}, "", "testdata/maxdir\n\ntestdata/maxdir/one\n", EX_OK, "/1\n", ""},
+		{[]string{"-from", "-", "-0"}, "", "testdata/maxdir\x00", EX_OK, "/3", ""},
+		{[]string{"-from", "-"}, "", "", EX_OK, "", ""}, // Empty list scans nothing
+		{[]string{"-from", "testdata/noexist"}, "", "", EX_NOINPUT, "", "no such file"},
+		{[]string{"-0"}, "", "", EX_USAGE, "", "only meaningful"},
+		{[]string{"-listing", "-"}, "", "1700000000.5 d /srv\n1700000001.0 f /srv/www/index.html\n",
+			EX_OK, ":f:/srv/www/index.html\n", ""},
+		{[]string{"-listing", "-"}, "", "garbage\n", EX_NOINPUT, "", "-:1: not in ls"},
+		{[]string{"-listing", "-", "-from", "-"}, "", "", EX_USAGE, "", "both read stdin"},
+		{[]string{"-tui", "-watch"}, "", "", EX_USAGE, "", "cannot be used together"},
+		{[]string{"-watch", "-jump", "x"}, "", "", EX_USAGE, "", "-jump and -watch cannot"},
+		{[]string{"-exec", "echo '{dir}"}, "", "", EX_USAGE, "", "unterminated ' quote"},
+		{[]string{"-exec", "true", "-exec+", "true"}, "", "", EX_USAGE, "", "-exec and -exec+ cannot"},
+		{[]string{"-expect-depth", "1"}, "", "", EX_USAGE, "", "only meaningful with -expect-active"},
+		{[]string{"-profile", "monitor", "testdata/maxdir"}, "testdata/profiles", "", EX_OK,
+			":d:testdata/maxdir/", ""}, // expect-depth from defaults.conf is harmless
+		{[]string{"-profile", "monitor", "-expect-active", "99Y", "testdata/maxdir"}, "testdata/profiles", "",
+			checkOK, "directories=3 ", ""},
+		{[]string{"-expect-quiet", "1h", "-watch"}, "", "", EX_USAGE, "", "-expect-quiet and -watch cannot"},
+		{[]string{"-interval", "1m", "-watch"}, "", "", EX_USAGE, "", "-interval and -watch cannot"},
+		{[]string{"-interval", "0s"}, "", "", EX_USAGE, "", "-interval '0s' must be greater than zero"},
+		{[]string{"-interval", "-1m"}, "", "", EX_USAGE, "", "-interval '-1m0s' must be greater"},
+		{[]string{"-profile", "nope"}, "testdata/profiles", "", EX_CONFIG, "", "Unknown profile 'nope'"},
+		{[]string{"-profile", "nope", "-v"}, "testdata/profiles", "", EX_OK, "Version:", ""},
+		{[]string{"-age", "99Y", "testdata/maxdir"}, "testdata/profiles", "", EX_OK, ":d:testdata/maxdir/", ""},
+		{[]string{"-profile", "logs", "-age", "99Y", "testdata/maxdir"}, "testdata/profiles", "", EX_OK,
+			":f:testdata/maxdir/", ""},
+		{[]string{"-format", "json"}, "", "", EX_USAGE, "", "must be one of prometheus, text"},
+		{[]string{"-format", "prometheus", "-tui"}, "", "", EX_USAGE, "", "-format and -tui cannot"},
+		{[]string{"-format", "prometheus", "-pstats", "testdata/maxdir"}, "", "", EX_OK,
+			"fad_scan_errors 0\n", ""},
+		{[]string{"-serve", "256.0.0.1:http"}, "", "", EX_UNAVAILABLE, "", "Error:"},
+		{[]string{"-serve", "127.0.0.1:0", "-rescan", "-1s"}, "", "", EX_USAGE, "", "-rescan '-1s' must not be negative"},
+		{[]string{"-shell-init", "csh"}, "", "", EX_USAGE, "", "must be one of"},
+		{[]string{"-shell-init", "bash"}, "", "", EX_OK, "complete -o filenames -F _fad fad fcd\n", ""},
 	}
 
 	for ix, tc := range testCases {
 		var stdout, stderr bytes.Buffer
 		ex := realMain(time.Now(), tc.options,
-			func() (string, error) { return tc.config, nil },
-			&stdout, &stderr)
+			func() (string, error) { return tc.config, nil }, noEnv,
+			strings.NewReader(tc.stdin), &stdout, &stderr)
 		if ex != tc.excode {
 			t.Error(ix, "Expected exit code of", tc.excode, "got", ex)
 		}
@@ -58,3 +98,19 @@ func TestMainOptions(t *testing.T) {
 		}
 	}
 }
+
+// TestMainEnv checks that the environment is only read via the lookup function.
+func TestMainEnv(t *testing.T) {
+	env := map[string]string{"FAD_COUNT": "1", "FAD_AGE": "1x"}
+	var stdout, stderr bytes.Buffer
+	ex := realMain(time.Now(), []string{"-age", "99Y", "testdata/maxdir"},
+		func() (string, error) { return "", nil },
+		func(name string) (string, bool) { v, ok := env[name]; return v, ok },
+		nil, &stdout, &stderr)
+	if ex != EX_OK || strings.Count(stdout.String(), "\n") != 1 {
+		t.Error("FAD_COUNT should limit output", ex, stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "Warning: Environment FAD_AGE") {
+		t.Error("FAD_AGE should warn", stderr.String())
+	}
+}
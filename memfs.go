@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// memFS is a read-only, metadata-only, in-memory file system which presents virtual
+// trees, such as the contents of an archive, to the scanner. Entries have names, modes
+// and modification times, but no content.
+//
+// Directories which are implied by the name of an entry, but which are never added in
+// their own right, are given the modification time of their youngest descendant for
+// display purposes. As that time is not their own, the scanner never treats an implied
+// directory as its own youngest entry, see impliedDir, so it never appears to have
+// deletion-only activity.
+//
+// memFS implements fs.FS, fs.ReadDirFS and fs.StatFS.
+type memFS struct {
+	root *memNode
+}
+
+type memNode struct {
+	name     string
+	mode     fs.FileMode
+	modTime  time.Time
+	sd       statDetails // Returned by Sys()
+	implied  bool
+	children map[string]*memNode // Only populated for directories
+}
+
+func newMemFS() *memFS {
+	return &memFS{root: &memNode{name: ".", mode: fs.ModeDir | 0555, implied: true,
+		children: make(map[string]*memNode)}}
+}
+
+// cleanMemName converts an archive-style name such as "./etc/hosts" or "/etc/" into the
+// fs.ValidPath form of "etc/hosts" or "etc". Names which try to escape the root with ".."
+// are anchored at the root. Names which cannot be converted return false.
+func cleanMemName(name string) (string, bool) {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimPrefix(name, "/")
+	if len(name) == 0 {
+		name = "."
+	}
+
+	return name, fs.ValidPath(name)
+}
+
+// add inserts or replaces the entry called name. Any missing parent directories are
+// implied. Adding a name which already exists replaces its details but retains any
+// children. The root directory cannot be replaced.
+func (m *memFS) add(name string, mode fs.FileMode, modTime time.Time, sd statDetails) error {
+	name, ok := cleanMemName(name)
+	if !ok {
+		return &fs.PathError{Op: "add", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return nil
+	}
+
+	parent := m.root
+	elems := strings.Split(name, "/")
+	for _, elem := range elems[:len(elems)-1] {
+		child, ok := parent.children[elem]
+		if !ok {
+			child = &memNode{name: elem, mode: fs.ModeDir | 0555, implied: true,
+				children: make(map[string]*memNode)}
+			parent.children[elem] = child
+		} else if !child.mode.IsDir() { // A file is being used as a directory
+			return &fs.PathError{Op: "add", Path: name, Err: fs.ErrExist}
+		}
+		parent = child
+	}
+
+	base := elems[len(elems)-1]
+	node, ok := parent.children[base]
+	if !ok {
+		node = &memNode{name: base}
+		parent.children[base] = node
+	}
+	node.mode = mode
+	node.modTime = modTime
+	node.sd = sd
+	node.implied = false
+	if mode.IsDir() && node.children == nil {
+		node.children = make(map[string]*memNode)
+	}
+
+	// Propagate modTime up through all implied directories
+	parent = m.root
+	for _, elem := range elems[:len(elems)-1] {
+		parent.youngest(modTime)
+		parent = parent.children[elem]
+	}
+	parent.youngest(modTime)
+
+	return nil
+}
+
+// setRoot gives the root directory the details of an explicit entry, such as the "."
+// entry of an mtree specification, so that it is no longer implied. mode must be a
+// directory.
+func (m *memFS) setRoot(mode fs.FileMode, modTime time.Time, sd statDetails) {
+	m.root.mode = mode
+	m.root.modTime = modTime
+	m.root.sd = sd
+	m.root.implied = false
+}
+
+// youngest adjusts the modTime of an implied directory if t is younger.
+func (n *memNode) youngest(t time.Time) {
+	if n.implied && t.After(n.modTime) {
+		n.modTime = t
+	}
+}
+
+func (m *memFS) lookup(op, name string) (*memNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	node := m.root
+	if name == "." {
+		return node, nil
+	}
+	for _, elem := range strings.Split(name, "/") {
+		child, ok := node.children[elem]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		node = child
+	}
+
+	return node, nil
+}
+
+// Open meets the fs.FS interface.
+func (m *memFS) Open(name string) (fs.File, error) {
+	node, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memFile{node: node}, nil
+}
+
+// Stat meets the fs.StatFS interface.
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	node, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.info(), nil
+}
+
+// ReadDir meets the fs.ReadDirFS interface.
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	node, err := m.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	return node.dirEntries(), nil
+}
+
+func (n *memNode) info() fs.FileInfo {
+	return &memInfo{n}
+}
+
+func (n *memNode) dirEntries() []fs.DirEntry {
+	des := make([]fs.DirEntry, 0, len(n.children))
+	for _, child := range n.children {
+		des = append(des, fs.FileInfoToDirEntry(child.info()))
+	}
+	sort.Slice(des, func(i, j int) bool { return des[i].Name() < des[j].Name() })
+
+	return des
+}
+
+// memInfo meets the fs.FileInfo interface
+type memInfo struct {
+	*memNode
+}
+
+func (mi *memInfo) Name() string       { return mi.name }
+func (mi *memInfo) Size() int64        { return 0 }
+func (mi *memInfo) Mode() fs.FileMode  { return mi.mode }
+func (mi *memInfo) ModTime() time.Time { return mi.modTime }
+func (mi *memInfo) IsDir() bool        { return mi.mode.IsDir() }
+func (mi *memInfo) Sys() any           { return mi.sd }
+
+// impliedDir returns true if fi is a memFS directory which was implied by the names of
+// its descendants rather than added in its own right.
+func impliedDir(fi fs.FileInfo) bool {
+	mi, ok := fi.(*memInfo)
+
+	return ok && mi.implied
+}
+
+// memFile meets the fs.File and fs.ReadDirFile interfaces. It has no content.
+type memFile struct {
+	node    *memNode
+	entries []fs.DirEntry // Remaining entries for ReadDir(n)
+	read    bool          // ReadDir has been called at least once
+}
+
+func (mf *memFile) Stat() (fs.FileInfo, error) { return mf.node.info(), nil }
+func (mf *memFile) Read([]byte) (int, error)   { return 0, io.EOF }
+func (mf *memFile) Close() error               { return nil }
+
+func (mf *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !mf.node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: mf.node.name, Err: errors.New("not a directory")}
+	}
+	if !mf.read {
+		mf.entries = mf.node.dirEntries()
+		mf.read = true
+	}
+	if n <= 0 {
+		des := mf.entries
+		mf.entries = nil
+		return des, nil
+	}
+	if len(mf.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(mf.entries))
+	des := mf.entries[:n]
+	mf.entries = mf.entries[n:]
+
+	return des, nil
+}
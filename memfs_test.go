@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMemFSCleanName(t *testing.T) {
+	testCases := []struct {
+		name   string
+		expect string
+		ok     bool
+	}{
+		{"./etc/hosts", "etc/hosts", true},
+		{"/etc/", "etc", true},
+		{"etc//rc.d/../hosts", "etc/hosts", true},
+		{"./", ".", true},
+		{`dos\style`, "dos/style", true},
+		{"../../etc/passwd", "etc/passwd", true}, // Clean of rooted path drops ".."
+	}
+
+	for ix, tc := range testCases {
+		got, ok := cleanMemName(tc.name)
+		if got != tc.expect || ok != tc.ok {
+			t.Error(ix, "Expected", tc.expect, tc.ok, "got", got, ok)
+		}
+	}
+}
+
+func TestMemFS(t *testing.T) {
+	base := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	mfs := newMemFS()
+	for _, e := range []struct {
+		name   string
+		mode   fs.FileMode
+		offset time.Duration
+	}{
+		{"etc/", fs.ModeDir | 0755, 0},
+		{"etc/hosts", 0644, time.Hour},
+		{"var/log/messages", 0644, 2 * time.Hour}, // Implies var and var/log
+		{"var/log/old", 0644, time.Minute},
+		{"var/run.sock", fs.ModeSocket, 0},
+	} {
+		err := mfs.add(e.name, e.mode, base.Add(e.offset), statDetails{})
+		if err != nil {
+			t.Fatal(e.name, err)
+		}
+	}
+
+	err := fstest.TestFS(mfs, "etc/hosts", "var/log/messages", "var/log/old", "var/run.sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Explicit directories retain their time, implied directories take youngest
+	for _, tc := range []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"etc", 0},
+		{"var", 2 * time.Hour},
+		{"var/log", 2 * time.Hour},
+		{".", 2 * time.Hour},
+	} {
+		fi, err := fs.Stat(mfs, tc.name)
+		if err != nil {
+			t.Fatal(tc.name, err)
+		}
+		if !fi.ModTime().Equal(base.Add(tc.offset)) {
+			t.Error(tc.name, "Expected", base.Add(tc.offset), "got", fi.ModTime())
+		}
+	}
+
+	// Using a file as a directory is an error
+	err = mfs.add("etc/hosts/bogus", 0644, base, statDetails{})
+	if !errors.Is(err, fs.ErrExist) {
+		t.Error("Expected ErrExist, not", err)
+	}
+
+	_, err = mfs.ReadDir("etc/hosts")
+	if err == nil {
+		t.Error("Expected ReadDir of a file to fail")
+	}
+	_, err = mfs.Stat("noexist")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("Expected ErrNotExist, not", err)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mtree specifications, as produced by the BSD mtree(8) command and by libarchive, are
+// loaded as a memFS using the "time=" keyword for modification times. Both the
+// hierarchical format, where directories change the current directory until a matching
+// "..", and the full path format, where every name contains a slash, are supported.
+//
+// Only the keywords relevant to fad are interpreted: type, time, mode, uid and gid. All
+// others are accepted and ignored. Entries without a time keyword are given the zero
+// time and thus never appear in the output unless they are directories with younger
+// descendants.
+
+// mtreeSniffSize is the amount of a file examined when looking for an mtree signature.
+const mtreeSniffSize = 1024
+
+// isMtree returns true if the file at path starts with the "#mtree" signature written by
+// libarchive and NetBSD, or with the header comment block written by FreeBSD mtree -c,
+// which contains a "tree:" line.
+func isMtree(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, mtreeSniffSize)
+	n, _ := io.ReadFull(f, buf)
+	buf = buf[:n]
+	if bytes.HasPrefix(buf, []byte("#mtree")) {
+		return true
+	}
+
+	for _, line := range strings.Split(string(buf), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		if strings.HasPrefix(strings.TrimSpace(line[1:]), "tree:") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func loadMtree(path string) (fs.FS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readMtree(path, f)
+}
+
+// mtreeKeywords holds the keyword values of the /set defaults or of a single entry.
+type mtreeKeywords map[string]string
+
+// readMtree constructs a memFS from an mtree specification.
+func readMtree(path string, r io.Reader) (fs.FS, error) {
+	mfs := newMemFS()
+	set := make(mtreeKeywords)
+	var cwd []string // Current directory in hierarchical format
+	var lineNumber, startLine int
+	var line string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024) // Long lines occur with many keywords
+	for scanner.Scan() {
+		lineNumber++
+		text := scanner.Text()
+		if len(line) == 0 {
+			startLine = lineNumber
+		}
+		if strings.HasSuffix(text, "\\") && !strings.HasSuffix(text, "\\\\") {
+			line += strings.TrimSuffix(text, "\\") + " "
+			continue
+		}
+		line += text
+		fields := strings.Fields(line)
+		line = ""
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		lineError := func(err error) error {
+			return fmt.Errorf("%s:%d: %w", path, startLine, err)
+		}
+
+		switch fields[0] {
+		case "/set":
+			for k, v := range parseMtreeKeywords(fields[1:]) {
+				set[k] = v
+			}
+			continue
+		case "/unset":
+			for _, k := range fields[1:] {
+				if k == "all" {
+					set = make(mtreeKeywords)
+				} else {
+					delete(set, k)
+				}
+			}
+			continue
+		case "..":
+			if len(cwd) == 0 {
+				return nil, lineError(errors.New(".. above root of specification"))
+			}
+			cwd = cwd[:len(cwd)-1]
+			continue
+		}
+
+		name, err := mtreeUnvis(fields[0])
+		if err != nil {
+			return nil, lineError(err)
+		}
+		kw := make(mtreeKeywords)
+		for k, v := range set {
+			kw[k] = v
+		}
+		for k, v := range parseMtreeKeywords(fields[1:]) {
+			kw[k] = v
+		}
+		mode, modTime, sd, err := kw.details()
+		if err != nil {
+			return nil, lineError(err)
+		}
+
+		fullPath := strings.Contains(name, "/")
+		entryName := name
+		if !fullPath {
+			entryName = strings.Join(append(cwd[:len(cwd):len(cwd)], name), "/")
+		}
+		if clean, _ := cleanMemName(entryName); clean == "." && mode.IsDir() { // The root
+			mfs.setRoot(mode, modTime, sd)
+			continue
+		}
+		err = mfs.add(entryName, mode, modTime, sd)
+		if errors.Is(err, fs.ErrInvalid) { // Unrepresentable name - just skip
+			continue
+		}
+		if err != nil {
+			return nil, lineError(err)
+		}
+
+		// In hierarchical format a directory becomes the current directory until its
+		// matching "..". The root "." is the initial current directory.
+		if !fullPath && mode.IsDir() && name != "." {
+			cwd = append(cwd, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return mfs, nil
+}
+
+// parseMtreeKeywords converts "key=value" fields into a map. Keywords without a value,
+// such as "nochange" and "optional", are given an empty value.
+func parseMtreeKeywords(fields []string) mtreeKeywords {
+	kw := make(mtreeKeywords)
+	for _, f := range fields {
+		k, v, _ := strings.Cut(f, "=")
+		kw[k] = v
+	}
+
+	return kw
+}
+
+// mtreeTypes maps the mtree type keyword values to file modes.
+var mtreeTypes = map[string]fs.FileMode{
+	"file":   0,
+	"dir":    fs.ModeDir,
+	"link":   fs.ModeSymlink,
+	"block":  fs.ModeDevice,
+	"char":   fs.ModeDevice | fs.ModeCharDevice,
+	"fifo":   fs.ModeNamedPipe,
+	"socket": fs.ModeSocket,
+}
+
+// details returns the attributes of an entry from its keywords. Missing keywords take
+// the mtree default of a regular file.
+func (kw mtreeKeywords) details() (mode fs.FileMode, modTime time.Time, sd statDetails, err error) {
+	if t, ok := kw["type"]; ok {
+		var ok bool
+		mode, ok = mtreeTypes[t]
+		if !ok {
+			return mode, modTime, sd, fmt.Errorf("unknown type '%s'", t)
+		}
+	}
+
+	if m, ok := kw["mode"]; ok {
+		perm, err := strconv.ParseUint(m, 8, 32)
+		if err != nil {
+			return mode, modTime, sd, fmt.Errorf("invalid mode '%s'", m)
+		}
+		mode |= fs.FileMode(perm) & fs.ModePerm
+	}
+
+	// The time keyword is seconds and nanoseconds separated by a period. Note that the
+	// nanoseconds are an integer count, not a decimal fraction, so "1.5" is one second
+	// and five nanoseconds.
+	if tv, ok := kw["time"]; ok {
+		secs, nsecs, _ := strings.Cut(tv, ".")
+		s, err := strconv.ParseInt(secs, 10, 64)
+		if err != nil {
+			return mode, modTime, sd, fmt.Errorf("invalid time '%s'", tv)
+		}
+		var ns int64
+		if len(nsecs) > 0 {
+			ns, err = strconv.ParseInt(nsecs, 10, 64)
+			if err != nil {
+				return mode, modTime, sd, fmt.Errorf("invalid time '%s'", tv)
+			}
+		}
+		modTime = time.Unix(s, ns)
+	}
+
+	uid, hasUID := kw["uid"]
+	gid, hasGID := kw["gid"]
+	if hasUID && hasGID {
+		u, uErr := strconv.ParseUint(uid, 10, 32)
+		g, gErr := strconv.ParseUint(gid, 10, 32)
+		if uErr == nil && gErr == nil {
+			sd = statDetails{hasOwner: true, uid: uint32(u), gid: uint32(g)}
+		}
+	}
+
+	return mode, modTime, sd, nil
+}
+
+// mtreeUnvis decodes the vis(3) encoding used for names in mtree specifications. The
+// common encodings are a backslash followed by three octal digits and the C-style
+// escapes.
+func mtreeUnvis(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	for ix := 0; ix < len(s); ix++ {
+		if s[ix] != '\\' {
+			sb.WriteByte(s[ix])
+			continue
+		}
+		ix++
+		if ix == len(s) {
+			return "", fmt.Errorf("trailing backslash in '%s'", s)
+		}
+		switch c := s[ix]; c {
+		case '0', '1', '2', '3':
+			if ix+2 >= len(s) {
+				return "", fmt.Errorf("short octal escape in '%s'", s)
+			}
+			v, err := strconv.ParseUint(s[ix:ix+3], 8, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid octal escape in '%s'", s)
+			}
+			sb.WriteByte(byte(v))
+			ix += 2
+		case 's':
+			sb.WriteByte(' ')
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		default: // Includes \\ and \#
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String(), nil
+}
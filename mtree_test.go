@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMtreeRead(t *testing.T) {
+	spec := `#mtree
+# Hierarchical format as produced by mtree -c
+/set type=file uid=0 gid=0 mode=0644
+.               type=dir mode=0755 time=1700000000.0
+    etc             type=dir mode=0755 time=1700000100.0
+        hosts           time=1700000200.500
+        rc.conf         uid=1001 gid=20 \
+                        time=1700000300.0
+        my\040file      time=1700000400.0
+        ssl             type=dir time=1700000050.0
+            cert.pem        type=link time=1700000060.0
+        ..
+    ..
+/unset uid gid
+    var             type=dir time=1700000000.0
+    ..
+./usr/local/bin/fad time=1700000500.0
+`
+	fsys, err := readMtree("spec", strings.NewReader(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		mode    fs.FileMode
+		modTime time.Time
+		owner   bool
+		uid     uint32
+	}{
+		{".", fs.ModeDir | 0755, time.Unix(1700000000, 0), true, 0}, // Not the youngest descendant
+		{"etc", fs.ModeDir | 0755, time.Unix(1700000100, 0), true, 0},
+		{"etc/hosts", 0644, time.Unix(1700000200, 500), true, 0},
+		{"etc/rc.conf", 0644, time.Unix(1700000300, 0), true, 1001},
+		{"etc/my file", 0644, time.Unix(1700000400, 0), true, 0},
+		{"etc/ssl/cert.pem", fs.ModeSymlink | 0644, time.Unix(1700000060, 0), true, 0},
+		{"var", fs.ModeDir | 0644, time.Unix(1700000000, 0), false, 0},
+		{"usr", fs.ModeDir | 0555, time.Unix(1700000500, 0), false, 0}, // Implied
+		{"usr/local/bin/fad", 0644, time.Unix(1700000500, 0), false, 0},
+	}
+
+	for ix, tc := range testCases {
+		fi, err := fs.Stat(fsys, tc.name)
+		if err != nil {
+			t.Error(ix, tc.name, err)
+			continue
+		}
+		if fi.Mode() != tc.mode {
+			t.Error(ix, tc.name, "Mode expected", tc.mode, "got", fi.Mode())
+		}
+		if !fi.ModTime().Equal(tc.modTime) {
+			t.Error(ix, tc.name, "ModTime expected", tc.modTime, "got", fi.ModTime())
+		}
+		sd := getStatDetails(fi)
+		if sd.hasOwner != tc.owner || sd.uid != tc.uid {
+			t.Error(ix, tc.name, "Owner expected", tc.owner, tc.uid, "got", sd)
+		}
+	}
+}
+
+func TestMtreeReadErrors(t *testing.T) {
+	testCases := []struct {
+		spec string
+		err  string
+	}{
+		{"..\n", "spec:1: .. above root"},
+		{"\n\nfoo type=door\n", "spec:3: unknown type 'door'"},
+		{"foo mode=999\n", "invalid mode '999'"},
+		{"foo time=yesterday\n", "invalid time 'yesterday'"},
+		{"foo time=1.x\n", "invalid time '1.x'"},
+		{"foo\\0\n", "short octal escape"},
+		{"foo\\ time=1\n", "trailing backslash"},
+		{"a time=1\na/b time=2\n", "file already exists"},
+	}
+
+	for ix, tc := range testCases {
+		_, err := readMtree("spec", strings.NewReader(tc.spec))
+		if err == nil {
+			t.Error(ix, "Expected error", tc.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%d Wrong error returned. Want '%s' got '%s'\n", ix, tc.err, err.Error())
+		}
+	}
+}
+
+func TestMtreeUnvis(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out string
+	}{
+		{"plain", "plain"},
+		{"a\\040b", "a b"},
+		{"a\\sb\\tc", "a b\tc"},
+		{"back\\\\slash", "back\\slash"},
+		{"\\#hash", "#hash"},
+		{"\\303\\251t\\303\\251", "été"},
+	}
+
+	for ix, tc := range testCases {
+		out, err := mtreeUnvis(tc.in)
+		if err != nil {
+			t.Error(ix, err)
+			continue
+		}
+		if out != tc.out {
+			t.Error(ix, "Expected", tc.out, "got", out)
+		}
+	}
+}
+
+func TestMtreeSniff(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		content string
+		expect  bool
+	}{
+		{"#mtree v2.0\n. type=dir\n", true},
+		{"#\t   user: root\n#\tmachine: host\n#\t   tree: /etc\n", true},
+		{"# A comment\n. type=dir\n", false},
+		{"#!/bin/sh\n", false},
+		{"", false},
+	}
+
+	for ix, tc := range testCases {
+		path := filepath.Join(dir, "manifest")
+		if err := os.WriteFile(path, []byte(tc.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if isMtree(path) != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "for", tc.content)
+		}
+	}
+	if isMtree(filepath.Join(dir, "noexist")) {
+		t.Error("Missing file should not be an mtree")
+	}
+}
+
+// Test that mtree specifications are recognized by suffix and by content and that the
+// normal candidate selection applies.
+func TestMtreeScan(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	stamp := func(d time.Duration) string {
+		t := now.Add(-d)
+		return fmt.Sprintf("time=%d.%d", t.Unix(), t.Nanosecond())
+	}
+	spec := "#mtree\n" +
+		". type=dir " + stamp(10*time.Hour) + "\n" +
+		"./docs type=dir " + stamp(10*time.Hour) + "\n" +
+		"./docs/README.md " + stamp(3*time.Hour) + "\n" +
+		"./src type=dir " + stamp(10*time.Hour) + "\n" +
+		"./src/main.go " + stamp(time.Hour) + "\n" +
+		"./src/.git type=dir " + stamp(time.Minute) + "\n" +
+		"./src/.git/HEAD " + stamp(time.Minute) + "\n"
+
+	for _, name := range []string{"host.mtree", "host.MTREE", "METALOG"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(spec), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		paths, stderr := testArchiveScan(t, path)
+		exp := path + "!/src/main.go|" + path + "!/docs/README.md"
+		got := strings.Join(paths, "|")
+		if got != exp {
+			t.Errorf("%s: Expected '%s' got '%s' stderr '%s'\n", name, exp, got, stderr)
+		}
+	}
+}
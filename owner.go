@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// lookupOwnerID converts a user or group name to its numeric id. A numeric value is
+// accepted as-is without consulting the system databases.
+func lookupOwnerID(name string, lookup func(string) (string, error)) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+
+	idString, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(idString, 10, 32)
+	if err != nil { // Probably Windows SIDs which we don't grok
+		return 0, fmt.Errorf("'%s' has non-numeric id '%s'", name, idString)
+	}
+
+	return uint32(id), nil
+}
+
+func lookupUserID(name string) (string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+
+	return u.Uid, nil
+}
+
+func lookupGroupID(name string) (string, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Gid, nil
+}
+
+// compileOwners populates the user and group id maps from the comma-string flags.
+func (cfg *config) compileOwners() error {
+	if len(cfg.ownerUsers.v) > 0 {
+		for _, f := range strings.Split(cfg.ownerUsers.v, commaDelimiter) {
+			id, err := lookupOwnerID(f, lookupUserID)
+			if err != nil {
+				return fmt.Errorf("Error: -user '%s': %w", f, err)
+			}
+			cfg.ownerUsersMap[id] = true
+		}
+	}
+
+	if len(cfg.ownerGroups.v) > 0 {
+		for _, f := range strings.Split(cfg.ownerGroups.v, commaDelimiter) {
+			id, err := lookupOwnerID(f, lookupGroupID)
+			if err != nil {
+				return fmt.Errorf("Error: -group '%s': %w", f, err)
+			}
+			cfg.ownerGroupsMap[id] = true
+		}
+	}
+
+	return nil
+}
+
+// matchesOwner returns true if the owner filters are not set or the owner details
+// satisfy all the filters which are set. If the filters are set and the owner details
+// are not available, the entry never matches.
+func (cfg *config) matchesOwner(sd statDetails) bool {
+	if len(cfg.ownerUsersMap) == 0 && len(cfg.ownerGroupsMap) == 0 {
+		return true
+	}
+	if !sd.hasOwner {
+		return false
+	}
+	if len(cfg.ownerUsersMap) > 0 {
+		if _, ok := cfg.ownerUsersMap[sd.uid]; !ok {
+			return false
+		}
+	}
+	if len(cfg.ownerGroupsMap) > 0 {
+		if _, ok := cfg.ownerGroupsMap[sd.gid]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ownerNames caches uid to username lookups for printing.
+type ownerNames map[uint32]string
+
+// name returns the username of the owner, or the numeric uid if the lookup fails, or "?"
+// if the owner details are not available.
+func (on ownerNames) name(sd statDetails) string {
+	if !sd.hasOwner {
+		return "?"
+	}
+	if n, ok := on[sd.uid]; ok {
+		return n
+	}
+
+	uidString := strconv.FormatUint(uint64(sd.uid), 10)
+	n := uidString
+	if u, err := user.LookupId(uidString); err == nil {
+		n = u.Username
+	}
+	on[sd.uid] = n
+
+	return n
+}
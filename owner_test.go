@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOwnerLookupID(t *testing.T) {
+	lookup := func(name string) (string, error) {
+		switch name {
+		case "alice":
+			return "1001", nil
+		case "windows":
+			return "S-1-5-21", nil
+		}
+		return "", errors.New("unknown " + name)
+	}
+
+	testCases := []struct {
+		name  string
+		id    uint32
+		error string
+	}{
+		{"0", 0, ""},
+		{"123", 123, ""},
+		{"alice", 1001, ""},
+		{"bob", 0, "unknown bob"},
+		{"windows", 0, "non-numeric"},
+	}
+
+	for ix, tc := range testCases {
+		id, err := lookupOwnerID(tc.name, lookup)
+		if err != nil {
+			if len(tc.error) == 0 || !strings.Contains(err.Error(), tc.error) {
+				t.Error(ix, "Unexpected error", err)
+			}
+			continue
+		}
+		if len(tc.error) > 0 {
+			t.Error(ix, "Expected error", tc.error)
+		}
+		if id != tc.id {
+			t.Error(ix, "Expected", tc.id, "got", id)
+		}
+	}
+}
+
+func TestOwnerMatches(t *testing.T) {
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError), nil)
+	none := statDetails{}
+	root := statDetails{hasOwner: true, uid: 0, gid: 0}
+	other := statDetails{hasOwner: true, uid: 10, gid: 20}
+
+	if !cfg.matchesOwner(none) || !cfg.matchesOwner(root) {
+		t.Error("Unset filters should always match")
+	}
+
+	cfg.ownerUsers.v = "10"
+	if err := cfg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.matchesOwner(none) || cfg.matchesOwner(root) || !cfg.matchesOwner(other) {
+		t.Error("User filter mismatch")
+	}
+
+	cfg.ownerGroups.v = "0"
+	if err := cfg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.matchesOwner(other) {
+		t.Error("Group filter should have excluded gid 20")
+	}
+}
+
+func TestOwnerCompileError(t *testing.T) {
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError), nil)
+	cfg.ownerUsers.v = "no-such-user-we-hope"
+	err := cfg.compile()
+	if err == nil || !strings.Contains(err.Error(), "-user") {
+		t.Error("Expected -user compile error, not", err)
+	}
+
+	cfg = newConfig(flag.NewFlagSet(Name, flag.ContinueOnError), nil)
+	cfg.ownerGroups.v = "no-such-group-we-hope"
+	err = cfg.compile()
+	if err == nil || !strings.Contains(err.Error(), "-group") {
+		t.Error("Expected -group compile error, not", err)
+	}
+}
+
+func TestOwnerNames(t *testing.T) {
+	names := make(ownerNames)
+	if got := names.name(statDetails{}); got != "?" {
+		t.Error("Expected '?' for missing owner, not", got)
+	}
+
+	names[4242] = "cached"
+	if got := names.name(statDetails{hasOwner: true, uid: 4242}); got != "cached" {
+		t.Error("Expected cached name, not", got)
+	}
+}
+
+// Check that entries not owned by the nominated user are ignored. Only meaningful on
+// systems which provide owner details.
+func TestOwnerScanner(t *testing.T) {
+	fi, err := os.Stat("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd := getStatDetails(fi)
+	if !sd.hasOwner {
+		t.Skip("No owner details on this system")
+	}
+
+	testCases := []struct {
+		uid    uint32
+		expect int
+	}{
+		{sd.uid, 3},
+		{sd.uid + 1, 0},
+	}
+
+	for ix, tc := range testCases {
+		var stderr bytes.Buffer
+		cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+			func() (string, error) { return "", nil })
+		cfg.ownerUsers.v = strconv.FormatUint(uint64(tc.uid), 10)
+		scn, can, err := testScannerSetup(cfg, &stderr, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scn.descendRoot("testdata/maxdir")
+		scn.wait()
+		if len(can.cf) != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "candidates, got", len(can.cf))
+		}
+		for _, c := range can.cf {
+			if !c.owner.hasOwner || c.owner.uid != tc.uid {
+				t.Error(ix, "Candidate has wrong owner", c.owner)
+			}
+		}
+	}
+}
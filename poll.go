@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Row statuses printed by -interval
+const (
+	pollNew     = "new"
+	pollMoved   = "moved"
+	pollDropped = "dropped"
+)
+
+// poll repeats the scan interval after the previous scan completed until stop is closed
+// and prints the rows which changed relative to the previous scan. scn is the result of
+// the first scan which is printed in full as new rows. Unlike -watch, no file system
+// events are needed so this works on network file systems and the like.
+//
+// As the interval is measured from the end of each scan, slow scans never run back to
+// back and the file system always has interval to recover. interval must be positive.
+func (scn *scanner) poll(out io.Writer, interval time.Duration, scan func(time.Time) *scanner,
+	stop <-chan struct{}) {
+	scn.printChanges(out, nil)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			next := scan(time.Now())
+			next.printChanges(out, scn)
+			scn = next
+			timer.Reset(interval)
+		}
+	}
+}
+
+// rowKey returns the path identifying the printed row of a candidate.
+func (scn *scanner) rowKey(c *candidate) string {
+	if scn.cfg.printDirname.v {
+		return c.dirName()
+	}
+
+	return filepath.Clean(c.path)
+}
+
+// printChanges prints the rows which are new compared to prev, the rows which have moved
+// relative to the other rows, and the rows of prev which have dropped out. A row is not
+// considered moved merely because rows above it were added or dropped, so new activity
+// at the top only prints the new row. Of the rows which remain, the largest set whose
+// relative order is unchanged stays put and the rest are moved. Each row is prefixed with
+// the time of the scan and its status. Dropped rows show their age as of this scan. If
+// prev is nil, all rows are new.
+func (scn *scanner) printChanges(out io.Writer, prev *scanner) {
+	ranks := make(map[string]int)
+	if prev != nil {
+		for ix, c := range prev.allCandidates.cf {
+			ranks[scn.rowKey(c)] = ix
+		}
+	}
+
+	added := newCandidates(0, age{})
+	moved := newCandidates(0, age{})
+	dropped := newCandidates(0, age{})
+	current := make(map[string]bool)
+	var remaining []*candidate // Rows in both scans in current order
+	var prevRanks []int        // Corresponding ranks in prev
+	for _, c := range scn.allCandidates.cf {
+		key := scn.rowKey(c)
+		current[key] = true
+		if rank, ok := ranks[key]; ok {
+			remaining = append(remaining, c)
+			prevRanks = append(prevRanks, rank)
+		} else {
+			added.cf = append(added.cf, c)
+		}
+	}
+	steady := longestIncreasing(prevRanks)
+	for ix, c := range remaining {
+		if !steady[ix] {
+			moved.cf = append(moved.cf, c)
+		}
+	}
+	if prev != nil {
+		for _, c := range prev.allCandidates.cf {
+			if !current[scn.rowKey(c)] {
+				nc := *c
+				nc.age.setFromTime(scn.baseTime, nc.modTime)
+				scn.futureDated(&nc)
+				dropped.cf = append(dropped.cf, &nc)
+			}
+		}
+	}
+
+	width := max(added.maxAgeWidth(), moved.maxAgeWidth(), dropped.maxAgeWidth())
+	stamp := scn.baseTime.Format(watchStampFormat)
+	for _, rows := range []struct {
+		status string
+		can    *candidates
+	}{{pollNew, added}, {pollMoved, moved}, {pollDropped, dropped}} {
+		scn.printPrefixed(out, fmt.Sprintf("%s %-7s ", stamp, rows.status), rows.can, width)
+	}
+}
+
+// longestIncreasing returns the indices of a longest strictly increasing subsequence of
+// seq. Where there is a choice, smaller values are kept so that a row which jumps up is
+// the one which moved rather than the rows it jumped over.
+func longestIncreasing(seq []int) map[int]bool {
+	var tails []int // Index in seq of the smallest tail of each subsequence length
+	prev := make([]int, len(seq))
+	for ix, v := range seq {
+		n := sort.Search(len(tails), func(k int) bool { return seq[tails[k]] >= v })
+		prev[ix] = -1
+		if n > 0 {
+			prev[ix] = tails[n-1]
+		}
+		if n == len(tails) {
+			tails = append(tails, ix)
+		} else {
+			tails[n] = ix
+		}
+	}
+
+	steady := make(map[int]bool)
+	if len(tails) > 0 {
+		for ix := tails[len(tails)-1]; ix >= 0; ix = prev[ix] {
+			steady[ix] = true
+		}
+	}
+
+	return steady
+}
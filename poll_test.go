@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPollScanner returns a scanner whose sorted candidates are the paths, each modified
+// the corresponding number of seconds before now.
+func testPollScanner(now time.Time, paths []string, secs []int) *scanner {
+	scn := &scanner{cfg: &config{}, baseTime: now, allCandidates: newCandidates(0, age{})}
+	for ix, p := range paths {
+		c := &candidate{}
+		c.set(p, 0, now, now.Add(-time.Duration(secs[ix])*time.Second))
+		scn.allCandidates.cf = append(scn.allCandidates.cf, c)
+	}
+	scn.allCandidates.sortAscending()
+
+	return scn
+}
+
+func TestPrintChanges(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	later := now.Add(time.Minute)
+	prev := testPollScanner(now, []string{"a/1", "b/1", "c/1"}, []int{10, 20, 30})
+	testCases := []struct {
+		paths []string
+		secs  []int
+		exp   string
+	}{
+		{[]string{"a/1", "b/1", "c/1"}, []int{70, 80, 90}, ""},
+		{[]string{"d/1", "a/1", "b/1", "c/1"}, []int{5, 70, 80, 90}, // Rows below a new row shift
+			"2024-03-01 12:01:00 new     5s:f:d/1\n"},
+		{[]string{"c/1", "a/1", "b/1"}, []int{5, 70, 80}, "2024-03-01 12:01:00 moved   5s:f:c/1\n"},
+		{[]string{"a/1", "c/1"}, []int{70, 90}, "2024-03-01 12:01:00 dropped 1m:f:b/1\n"},
+		{[]string{"b/2", "a/1"}, []int{1, 70}, "2024-03-01 12:01:00 new     1s:f:b/2\n" +
+			"2024-03-01 12:01:00 dropped 1m:f:b/1\n" +
+			"2024-03-01 12:01:00 dropped 2m:f:c/1\n"},
+	}
+
+	for ix, tc := range testCases {
+		var out bytes.Buffer
+		testPollScanner(later, tc.paths, tc.secs).printChanges(&out, prev)
+		if out.String() != tc.exp {
+			t.Errorf("%d Expected\n%sGot\n%s", ix, tc.exp, out.String())
+		}
+	}
+
+	// With -pdirname, a younger entry in the same directory is the same row
+	next := testPollScanner(later, []string{"b/2", "a/1", "c/1"}, []int{1, 70, 90})
+	next.cfg.printDirname.v = true
+	var out bytes.Buffer
+	next.printChanges(&out, prev)
+	exp := "2024-03-01 12:01:00 moved   1s:d:b\n"
+	if out.String() != exp {
+		t.Errorf("-pdirname Expected\n%sGot\n%s", exp, out.String())
+	}
+}
+
+func TestPoll(t *testing.T) {
+	now := time.Now()
+	scans := []*scanner{
+		testPollScanner(now, []string{"b", "a"}, []int{1, 2}),
+		testPollScanner(now, []string{"b", "a"}, []int{1, 2}),
+	}
+	stop := make(chan struct{})
+	scan := func(time.Time) *scanner {
+		scn := scans[0]
+		scans = scans[1:]
+		if len(scans) == 0 {
+			close(stop)
+		}
+		return scn
+	}
+
+	var out bytes.Buffer
+	testPollScanner(now, []string{"a"}, []int{1}).poll(&out, time.Millisecond, scan, stop)
+	got := out.String()
+	for _, exp := range []string{" new     1s:f:a\n", " new     1s:f:b\n"} {
+		if !strings.Contains(got, exp) {
+			t.Errorf("Missing %q in\n%s", exp, got)
+		}
+	}
+	if strings.Count(got, "\n") != 2 {
+		t.Error("Expected no changes from the last scan\n", got)
+	}
+}
+
+// TestPollSpacing checks that the interval is measured from the end of each scan so that
+// slow scans are never run back to back.
+func TestPollSpacing(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	now := time.Now()
+	stop := make(chan struct{})
+	var ends []time.Time
+	scan := func(start time.Time) *scanner {
+		if len(ends) > 0 && start.Sub(ends[len(ends)-1]) < interval {
+			t.Error("Scan started", start.Sub(ends[len(ends)-1]), "after the previous scan")
+		}
+		time.Sleep(2 * interval)
+		ends = append(ends, time.Now())
+		if len(ends) == 3 {
+			close(stop)
+		}
+		return testPollScanner(now, []string{"a"}, []int{1})
+	}
+	testPollScanner(now, []string{"a"}, []int{1}).poll(io.Discard, interval, scan, stop)
+}
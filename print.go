@@ -8,16 +8,43 @@ import (
 )
 
 func (scn *scanner) printCandidates(out io.Writer) {
-	fmtString := fmt.Sprintf("%%%ds:%%s:%%s\n", scn.allCandidates.maxAgeWidth()) // Determine age format
-	for _, cf := range scn.allCandidates.cf {
+	scn.printList(out, scn.allCandidates, scn.allCandidates.maxAgeWidth(), (*age).compactString)
+}
+
+// printFuture prints the future-dated candidates in a separate section with ages showing
+// how far in the future they are.
+func (scn *scanner) printFuture(out io.Writer) {
+	if len(scn.futureCandidates.cf) == 0 {
+		return
+	}
+	var width int
+	for _, cf := range scn.futureCandidates.cf {
+		width = max(width, len(cf.age.futureString()))
+	}
+	fmt.Fprintln(out, "Future-dated:")
+	scn.printList(out, scn.futureCandidates, width, (*age).futureString)
+}
+
+// printList prints each candidate in can with the age column formatted by ageString and
+// right-justified to width.
+func (scn *scanner) printList(out io.Writer, can *candidates, width int, ageString func(*age) string) {
+	fmtString := fmt.Sprintf("%%%ds:%%s:%%s\n", width) // Determine age format
+	names := make(ownerNames)
+	for _, cf := range can.cf {
 		p := cf.path
 		p = filepath.Clean(p) // Trim off any leading "./" or ".\" or whatever the OS prefers
 		fType := cf.fType()
-		if scn.cfg.printDirname.v { // If printing just dirname(path) then
+		if scn.cfg.printDirname.v && !cf.deleted { // If printing just dirname(path) then
 			p = filepath.Dir(p) // Trim path
 			fType = "d"         // and force type
 		}
-		fmt.Fprintf(out, fmtString, cf.age.compactString(), fType, p)
+		if scn.cfg.printOwner.v { // Owner is an extra column between type and path
+			fType += ":" + names.name(cf.owner)
+		}
+		if scn.cfg.printExts.v && len(cf.exts) > 0 { // Extension summary trails the path
+			p += " " + cf.exts
+		}
+		fmt.Fprintf(out, fmtString, ageString(&cf.age), fType, p)
 	}
 }
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 	"time"
 )
@@ -47,6 +48,68 @@ func TestPrintCandidates(t *testing.T) {
 	if got != exp {
 		t.Error("Print mismatch. Got\n", got, "Exp\n", exp)
 	}
+
+	c2.deleted = true // Deleted directories are not trimmed
+	out.Reset()
+	scn.printCandidates(&out)
+	got = out.String()
+	exp = "  1m:x:/var/log/system\n"
+	if !strings.Contains(got, exp) {
+		t.Error("Print deleted mismatch. Got\n", got, "Exp\n", exp)
+	}
+	c2.deleted = false
+
+	scn.cfg.printDirname.v = false
+	scn.cfg.printOwner.v = true
+	c1.owner = statDetails{hasOwner: true, uid: 4242}
+	out.Reset()
+	scn.printCandidates(&out)
+	got = out.String()
+	for _, exp := range []string{"  1s:f:4242:TODO\n", " 59s:f:?:/usr/local/etc/postfix/master.cf\n"} {
+		if !strings.Contains(got, exp) {
+			t.Error("Print owner mismatch. Got\n", got, "Exp\n", exp)
+		}
+	}
+
+	scn.cfg.printOwner.v = false
+	scn.cfg.printExts.v = true
+	c2.exts = "log:3 gz:1"
+	out.Reset()
+	scn.printCandidates(&out)
+	got = out.String()
+	for _, exp := range []string{"  1s:f:TODO\n", "  1m:f:/var/log/system log:3 gz:1\n"} {
+		if !strings.Contains(got, exp) {
+			t.Error("Print exts mismatch. Got\n", got, "Exp\n", exp)
+		}
+	}
+}
+
+func TestPrintFuture(t *testing.T) {
+	var out bytes.Buffer
+	var cfg config
+	scn := newScanner(&cfg, newConcurrencyController(1), newCandidates(10, age{}), time.Time{}, &out)
+	scn.printFuture(&out)
+	if out.Len() > 0 {
+		t.Error("Empty future candidates should not print anything, not", out.String())
+	}
+
+	var c1, c2 candidate
+	c1.path = "/tmp/from-the-future"
+	c1.age.seconds = -3 * day
+	c2.path = "/tmp/slightly-future"
+	c2.age.seconds = -59
+	scn.futureCandidates.addMaybe(&c2)
+	scn.futureCandidates.addMaybe(&c1)
+	scn.futureCandidates.sortAscending()
+	scn.printFuture(&out)
+	exp := `Future-dated:
+ +3D:f:/tmp/from-the-future
++59s:f:/tmp/slightly-future
+`
+	got := out.String()
+	if got != exp {
+		t.Error("Print mismatch. Got\n", got, "Exp\n", exp)
+	}
 }
 
 func TestPrintStats(t *testing.T) {
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Output formats selected with -format
+const (
+	formatText       = "text"
+	formatPrometheus = "prometheus"
+)
+
+var formatNames = []string{formatPrometheus, formatText}
+
+// promPrefix is prepended to all metric names.
+const promPrefix = Name + "_"
+
+// promEscape escapes a label value as required by the Prometheus text exposition format.
+func promEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// promFamily writes the HELP and TYPE lines which precede the samples of a metric. All
+// metrics are gauges as each run starts afresh.
+func promFamily(out io.Writer, name, help string) {
+	fmt.Fprintf(out, "# HELP %s%s %s\n# TYPE %s%s gauge\n", promPrefix, name, help, promPrefix, name)
+}
+
+// rootActivity returns the modification time of the youngest activity below each root
+// scanned, keyed by the root as displayed. Unlike candidates, activity is not limited by
+// -age so that the time since the last activity is always known. A root without any
+// qualifying activity, such as when every entry is ignored, has a zero time. Activity
+// must be tracked.
+func (scn *scanner) rootActivity() map[string]time.Time {
+	trees := make(map[*scanTree]time.Time)
+	for _, sDir := range scn.scanned {
+		if sDir.depth == 0 && sDir.name == "." {
+			trees[sDir.tree] = time.Time{}
+		}
+	}
+	for dir, c := range scn.activity {
+		sDir, ok := scn.scanned[dir]
+		if ok && c.modTime.After(trees[sDir.tree]) {
+			trees[sDir.tree] = c.modTime
+		}
+	}
+
+	roots := make(map[string]time.Time, len(trees))
+	for tree, newest := range trees {
+		roots[tree.display(".")] = newest
+	}
+
+	return roots
+}
+
+// printPrometheus prints the results as metrics in the Prometheus text exposition format
+// suitable for the node_exporter textfile collector. Labels are limited to paths and
+// types so the number of series is bounded by the number of roots and -count.
+func (scn *scanner) printPrometheus(out io.Writer, secs time.Duration) {
+	roots := scn.rootActivity()
+	names := make([]string, 0, len(roots))
+	for root := range roots {
+		names = append(names, root)
+	}
+	sort.Strings(names)
+	promFamily(out, "root_newest_activity_timestamp_seconds",
+		"Modification time of the youngest activity below each root, zero if none.")
+	for _, root := range names {
+		var ts int64
+		if !roots[root].IsZero() {
+			ts = roots[root].Unix()
+		}
+		fmt.Fprintf(out, "%sroot_newest_activity_timestamp_seconds{root=\"%s\"} %d\n",
+			promPrefix, promEscape(root), ts)
+	}
+
+	promFamily(out, "candidate_age_seconds", "Age of each active path as printed.")
+	for _, c := range scn.allCandidates.cf {
+		p := filepath.Clean(c.path)
+		fType := c.fType()
+		if scn.cfg.printDirname.v && !c.deleted {
+			p, fType = c.dirName(), fTypeDir
+		}
+		fmt.Fprintf(out, "%scandidate_age_seconds{path=\"%s\",type=\"%s\"} %d\n",
+			promPrefix, promEscape(p), fType, c.age.seconds)
+	}
+
+	for _, m := range []struct {
+		name  string
+		help  string
+		value any
+	}{
+		{"scan_timestamp_seconds", "Time the scan started.", scn.baseTime.Unix()},
+		{"scan_duration_seconds", "Time taken by the scan.", secs.Seconds()},
+		{"scan_found", "Active paths found.", len(scn.allCandidates.cf)},
+		{"scan_directories", "Directories scanned.", scn.dirCount},
+		{"scan_files", "Files examined.", scn.fileCount},
+		{"scan_others", "Other file system objects examined.", scn.otherCount},
+		{"scan_ignored", "File system objects ignored by filters.", scn.ignoreCount},
+		{"scan_errors", "File system objects which could not be accessed.", scn.errorCount},
+	} {
+		promFamily(out, m.name, m.help)
+		fmt.Fprintf(out, "%s%s %v\n", promPrefix, m.name, m.value)
+	}
+}
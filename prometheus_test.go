@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPromEscape(t *testing.T) {
+	testCases := []struct{ in, exp string }{
+		{"/var/log", "/var/log"},
+		{`C:\Logs`, `C:\\Logs`},
+		{`a "quoted" name`, `a \"quoted\" name`},
+		{"two\nlines", `two\nlines`},
+	}
+	for _, tc := range testCases {
+		got := promEscape(tc.in)
+		if got != tc.exp {
+			t.Errorf("promEscape(%q) got %q, expected %q", tc.in, got, tc.exp)
+		}
+	}
+}
+
+func TestPrintPrometheus(t *testing.T) {
+	base := t.TempDir()
+	now := time.Now()
+	old := now.Add(-time.Hour)
+	active := filepath.Join(base, "active")
+	quiet := filepath.Join(base, "quiet")
+	for _, f := range []struct {
+		path  string
+		mtime time.Time
+	}{
+		{filepath.Join(active, "sub", "log"), now.Add(-10 * time.Minute)},
+		{filepath.Join(active, "old"), old},
+		{filepath.Join(quiet, "log"), old},
+	} {
+		os.MkdirAll(filepath.Dir(f.path), 0700)
+		os.WriteFile(f.path, nil, 0600)
+		os.Chtimes(f.path, f.mtime, f.mtime)
+		os.Chtimes(filepath.Dir(f.path), old, old)
+	}
+	os.Chtimes(active, old, old)
+
+	var stdout, stderr strings.Builder
+	ec := realMain(now, []string{"-format", "prometheus", "-age", "30m", active, quiet},
+		func() (string, error) { return "", nil }, noEnv, nil, &stdout, &stderr)
+	if ec != EX_OK {
+		t.Fatal("Unexpected exit code", ec, stderr.String())
+	}
+	got := stdout.String()
+	for _, exp := range []string{
+		"# TYPE fad_root_newest_activity_timestamp_seconds gauge\n",
+		`fad_root_newest_activity_timestamp_seconds{root="` + active + `"} ` +
+			strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10) + "\n",
+		`fad_root_newest_activity_timestamp_seconds{root="` + quiet + `"} ` + // Regardless of -age
+			strconv.FormatInt(old.Unix(), 10) + "\n",
+		`fad_candidate_age_seconds{path="` + filepath.Join(active, "sub", "log") + `",type="f"} 600` + "\n",
+		"fad_scan_found 1\n",
+		"fad_scan_directories 3\n",
+		"fad_scan_errors 0\n",
+	} {
+		if !strings.Contains(got, exp) {
+			t.Errorf("Missing %q in\n%s", exp, got)
+		}
+	}
+	if strings.Count(got, "fad_candidate_age_seconds{") != 1 {
+		t.Error("Expected exactly one candidate in\n", got)
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"sync"
@@ -30,22 +32,23 @@ func (s *stats) sum() uint32 {
 	return s.errorCount + s.dirCount + s.fileCount + s.otherCount + s.ignoreCount
 }
 
-type readDirFunc func(name string) ([]fs.DirEntry, error)
-type fStatFunc func(f *os.File) (os.FileInfo, error)
-
-func defaultFStatFunc(f *os.File) (os.FileInfo, error) {
-	return f.Stat()
-}
-
 // scanner encapsulates the common structs used over the program lifetime.
 type scanner struct {
-	cfg           *config
-	cc            *concurrencyController
-	allCandidates *candidates
-	baseTime      time.Time
+	cfg              *config
+	cc               *concurrencyController
+	allCandidates    *candidates
+	futureCandidates *candidates // Only populated with -pfuture
+	baseTime         time.Time
+
+	visitMu sync.Mutex
+	roots   map[fileID]string // Command line directories which are scanned as roots
+	visited map[fileID]any    // All directories scanned to date
+
+	rootPaths []string // Command line paths remaining after setRoots()
 
-	rdf readDirFunc // Overrides of system functions for
-	fsf fStatFunc   // _testing.go functions
+	activityMu sync.Mutex
+	activity   map[string]*candidate // Youngest candidate of each directory, if tracked
+	scanned    map[string]*scanDir   // Every directory scanned, if activity is tracked
 
 	wg     sync.WaitGroup
 	stderr io.Writer
@@ -55,9 +58,130 @@ type scanner struct {
 func newScanner(cfg *config, cc *concurrencyController, allCandidates *candidates,
 	baseTime time.Time, stderr io.Writer) *scanner {
 	return &scanner{cfg: cfg, cc: cc, allCandidates: allCandidates,
-		baseTime: baseTime,
-		rdf:      os.ReadDir, fsf: defaultFStatFunc,
-		stderr: stderr}
+		futureCandidates: newCandidates(int(cfg.maxCount.v), age{}),
+		baseTime:         baseTime,
+		roots:            make(map[fileID]string),
+		visited:          make(map[fileID]any),
+		stderr:           stderr}
+}
+
+// scanDir records how a directory was scanned so that it can be rescanned in isolation.
+type scanDir struct {
+	tree  *scanTree
+	depth uint
+	name  string
+	sd    statDetails
+}
+
+// trackActivity causes every subsequent scan to record the youngest candidate of each
+// directory, regardless of whether it makes the printed list, along with the details
+// needed to rescan that directory. This supports modes which outlive a single scan.
+func (scn *scanner) trackActivity() {
+	scn.activity = make(map[string]*candidate)
+	scn.scanned = make(map[string]*scanDir)
+}
+
+// setActivity records the youngest candidate of dirName if activity is being tracked. A
+// nil candidate means that the directory currently has no qualifying activity.
+// Concurrency-safe.
+func (scn *scanner) setActivity(dirName string, c *candidate) {
+	if scn.activity == nil {
+		return
+	}
+	scn.activityMu.Lock()
+	defer scn.activityMu.Unlock()
+	if c == nil {
+		delete(scn.activity, dirName)
+	} else {
+		scn.activity[dirName] = c
+	}
+}
+
+// setScanned records the details of a scanned directory if activity is being tracked.
+// Concurrency-safe.
+func (scn *scanner) setScanned(dirName string, sDir *scanDir) {
+	if scn.scanned == nil {
+		return
+	}
+	scn.activityMu.Lock()
+	defer scn.activityMu.Unlock()
+	scn.scanned[dirName] = sDir
+}
+
+// scannedDirs returns a copy of the scanned directories which can be iterated while
+// scans are updating them. Concurrency-safe.
+func (scn *scanner) scannedDirs() map[string]*scanDir {
+	scn.activityMu.Lock()
+	defer scn.activityMu.Unlock()
+
+	return maps.Clone(scn.scanned)
+}
+
+// forget removes all record of dirName, typically because it no longer exists, so that it
+// can be scanned afresh if it re-appears. Not concurrency-safe.
+func (scn *scanner) forget(dirName string) {
+	if sDir, ok := scn.scanned[dirName]; ok && sDir.sd.hasID {
+		scn.visitMu.Lock()
+		delete(scn.visited, sDir.sd.id)
+		scn.visitMu.Unlock()
+	}
+	delete(scn.scanned, dirName)
+	delete(scn.activity, dirName)
+}
+
+// setRoots returns the list of command line directories with duplicates removed. A
+// duplicate is either the same path or, if the system supports it, the same device and
+// inode as a previous directory. The remaining directories are remembered as roots so
+// that they are only ever scanned as a root and not as a sub-directory of some other
+// root. This ensures that overlapping directories such as "~ ~/Projects" or bind-mounted
+// paths are only scanned and reported once under the most specific root.
+//
+// Directories which cannot be accessed are retained so that scan() reports the error.
+func (scn *scanner) setRoots(dirNames []string) (roots []string) {
+	paths := make(map[string]any)
+	for _, dirName := range dirNames {
+		if _, ok := paths[dirName]; ok {
+			continue
+		}
+		paths[dirName] = true
+
+		fi, err := os.Stat(dirName)
+		if err != nil {
+			roots = append(roots, dirName)
+			continue
+		}
+		sd := getStatDetails(fi)
+		if sd.hasID {
+			if _, ok := scn.roots[sd.id]; ok {
+				continue
+			}
+			scn.roots[sd.id] = dirName
+			scn.visited[sd.id] = true
+		}
+		roots = append(roots, dirName)
+	}
+
+	return
+}
+
+// firstVisit returns true if the sub-directory has not been previously scanned and is not
+// a root in its own right. Concurrency-safe.
+func (scn *scanner) firstVisit(path string, sd statDetails) bool {
+	if !sd.hasID { // Can only assume unique if we can't tell
+		return true
+	}
+
+	scn.visitMu.Lock()
+	defer scn.visitMu.Unlock()
+	if root, ok := scn.roots[sd.id]; ok && root != path {
+		return false // Let the root scan take care of it
+	}
+	if _, ok := scn.visited[sd.id]; ok {
+		return false
+	}
+	scn.visited[sd.id] = true
+
+	return true
 }
 
 // descend starts a new goroutine to scan the directory. Use concurrency control to limit the
@@ -72,11 +196,13 @@ func newScanner(cfg *config, cc *concurrencyController, allCandidates *candidate
 // immediately but the goroutines block on concurrencyControl. In short we use goroutines
 // as our "pending" work queue.
 //
+// The tree parameter identifies the file system containing dirName.
+//
 // The depth parameter says how far below the starting point the dirName is from the
 // starting directory. A value of zero means it is at the starting point. Since the
 // minimum relevant value of maxDepth is 1, that means that when depth reaches or exceeds
 // maxDepth, the descending stops.
-func (scn *scanner) descend(depth uint, dirName string) {
+func (scn *scanner) descend(tree *scanTree, depth uint, dirName string) {
 	if scn.cfg.maxDepth.v > 0 && depth >= scn.cfg.maxDepth.v {
 		return
 	}
@@ -85,7 +211,7 @@ func (scn *scanner) descend(depth uint, dirName string) {
 	atomic.AddUint32(&scn.dirCount, 1)
 	go func() {
 		scn.cc.start()
-		scn.scan(depth, dirName)
+		scn.scan(tree, depth, dirName)
 		scn.cc.done()
 		scn.wg.Done()
 	}()
@@ -100,23 +226,31 @@ func (scn *scanner) wait() {
 //
 // Scanning starts with dirName as the primordial youngest candidate on the basis that if
 // it has the most recent DTM that means that the last action in this directory was a
-// deletion. Otherwise some other file entry with a more recent DTM will replace it.
+// deletion. Otherwise some other file entry with a more recent DTM will replace it. A
+// directory which remains the youngest is marked as deleted. Directories implied by a
+// virtual tree have no DTM of their own so they are never the primordial candidate.
 //
 // All "ignore" filters apply before each entry is considered as a candidate file or a
-// sub-directory to scan. If maxAge is configured, files are age-checked before
-// considering as a candidate.
+// sub-directory to scan. The owner filters only apply to candidates as sub-directories
+// are always scanned regardless of their owner. Sub-directories which are roots in their
+// own right or which have already been scanned via some other path are not descended. If
+// maxAge is configured, files are age-checked before considering as a candidate. The
+// same age check determines which extensions are tallied for -pexts.
 //
-// readDirFunc enables testing of error conditions which are otherwise hard to synthesize
-// with testdata directories.
-func (scn *scanner) scan(depth uint, dirName string) {
+// Since all access is via tree.fsys, tests can readily synthesize trees with exact
+// timestamps and error conditions which are otherwise hard to create with testdata
+// directories.
+func (scn *scanner) scan(tree *scanTree, depth uint, name string) {
 	var youngest candidate // Almost always populated with something
+	dirName := tree.display(name)
 
 	// Populate "youngest" with parent dirName to capture possible deletion
 	// activity. dirName has already been vetted by ignore if it's a subdir and is
 	// purposely bypassed if it's a commandline dir.
 
-	dirFi, err := scn.getFileInfo(dirName)
+	dirFi, err := scn.getFileInfo(tree, name)
 	if err != nil {
+		scn.setActivity(dirName, nil)
 		atomic.AddUint32(&scn.errorCount, 1)
 		if !scn.cfg.suppressErrors.v {
 			fmt.Fprintln(scn.stderr, "Error:", err)
@@ -132,18 +266,31 @@ func (scn *scanner) scan(depth uint, dirName string) {
 		return
 	}
 
-	// Only set youngest if this type is not being ignored
-	if _, ok := scn.cfg.ignoreTypesMap[fTypeString(dirFi.Mode())]; !ok {
-		youngest.set(dirName, dirFi.Mode(), scn.baseTime, dirFi.ModTime())
-	} else {
+	// Only set youngest if this type and owner is not being ignored. -pdeleted needs
+	// the directory as a candidate regardless of its type as deletion-only activity
+	// is exactly what the user is looking for.
+	dirSd := getStatDetails(dirFi)
+	scn.setScanned(dirName, &scanDir{tree: tree, depth: depth, name: name, sd: dirSd})
+	if _, ok := scn.cfg.ignoreTypesMap[fTypeString(dirFi.Mode())]; ok && !scn.cfg.printDeleted.v {
 		atomic.AddUint32(&scn.ignoreCount, 1)
 		if scn.cfg.printIgnored.v {
 			fmt.Fprintf(scn.stderr, "Ignored type %s:%s\n", fTypeString(dirFi.Mode()), dirName)
 		}
+	} else if !scn.cfg.matchesOwner(dirSd) {
+		atomic.AddUint32(&scn.ignoreCount, 1)
+		if scn.cfg.printIgnored.v {
+			fmt.Fprintf(scn.stderr, "Ignored owner:%s\n", dirName)
+		}
+	} else if !impliedDir(dirFi) {
+		youngest.set(dirName, dirFi.Mode(), scn.baseTime, dirFi.ModTime())
+		youngest.owner = dirSd
+		if scn.excludeFuture(&youngest) {
+			youngest = candidate{}
+		}
 	}
 
 	// Get and scan of directory entries
-	dirents, err := scn.rdf(dirName)
+	dirents, err := scn.readDir(tree, name)
 	if err != nil {
 		atomic.AddUint32(&scn.errorCount, 1)
 		if !scn.cfg.suppressErrors.v {
@@ -152,6 +299,11 @@ func (scn *scanner) scan(depth uint, dirName string) {
 		dirents = []fs.DirEntry{} // Set to a known quantity and continue
 	}
 
+	var exts extCounts
+	if scn.cfg.printExts.v {
+		exts = make(extCounts)
+	}
+
 	for _, de := range dirents {
 		fi, err := de.Info() // Exclusively use FileInfo for file entry details
 		if err != nil {
@@ -162,7 +314,8 @@ func (scn *scanner) scan(depth uint, dirName string) {
 			continue
 		}
 
-		path := filepath.Join(dirName, fi.Name())
+		entryName := tree.join(name, fi.Name())
+		path := tree.display(entryName)
 		ignored := scn.cfg.ignore(path) // Apply ignore filters
 		if len(ignored) > 0 {
 			atomic.AddUint32(&scn.ignoreCount, 1)
@@ -172,8 +325,11 @@ func (scn *scanner) scan(depth uint, dirName string) {
 			continue
 		}
 
+		sd := getStatDetails(fi)
 		if fi.IsDir() { // If it's a sub-directory, descend and scan
-			scn.descend(depth+1, path)
+			if scn.firstVisit(path, sd) {
+				scn.descend(tree, depth+1, entryName)
+			}
 			continue
 		}
 
@@ -184,6 +340,14 @@ func (scn *scanner) scan(depth uint, dirName string) {
 			continue
 		}
 
+		if !scn.cfg.matchesOwner(sd) {
+			atomic.AddUint32(&scn.ignoreCount, 1)
+			if scn.cfg.printIgnored.v {
+				fmt.Fprintf(scn.stderr, "Ignored owner:%s\n", path)
+			}
+			continue
+		}
+
 		if fi.Mode().IsRegular() {
 			atomic.AddUint32(&scn.fileCount, 1)
 		} else {
@@ -191,6 +355,13 @@ func (scn *scanner) scan(depth uint, dirName string) {
 		}
 		var current candidate
 		current.set(path, fi.Mode(), scn.baseTime, fi.ModTime())
+		current.owner = sd
+		if scn.excludeFuture(&current) {
+			continue
+		}
+		if exts != nil && (scn.cfg.maxAge.seconds == 0 || !current.age.gt(scn.cfg.maxAge, true)) {
+			exts.add(fi.Name())
+		}
 
 		// Is current younger or equal to the previously discovered youngster?
 		// With equal ages, the preference is given to the later entry. This is
@@ -200,24 +371,182 @@ func (scn *scanner) scan(depth uint, dirName string) {
 		}
 	}
 
-	// Scan done. If a youngest was found, conditionally add to allCandidates.
-	if youngest.isSet() {
-		scn.allCandidates.addMaybe(&youngest)
+	final := scn.conclude(&youngest, dirName, exts)
+	scn.setActivity(dirName, final)
+	if final != nil {
+		scn.allCandidates.addMaybe(final)
 	}
 }
 
-// getFileInfo returns the os.FileInfo of path
-func (scn *scanner) getFileInfo(path string) (os.FileInfo, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("Getting File Info: %w", err)
+// conclude returns the youngest candidate of a completed directory scan if it is to be
+// added to allCandidates, or nil. If the youngest is still the directory itself then no
+// entry is younger and the most likely activity is a deletion so it gets its own type
+// which is subject to -itypes and -pdeleted.
+func (scn *scanner) conclude(youngest *candidate, dirName string, exts extCounts) *candidate {
+	if !youngest.isSet() {
+		return nil
+	}
+	youngest.deleted = youngest.path == dirName
+	if youngest.deleted {
+		if _, ok := scn.cfg.ignoreTypesMap[fTypeDeleted]; ok {
+			atomic.AddUint32(&scn.ignoreCount, 1)
+			if scn.cfg.printIgnored.v {
+				fmt.Fprintf(scn.stderr, "Ignored type %s:%s\n", fTypeDeleted, dirName)
+			}
+			return nil
+		}
+	} else if scn.cfg.printDeleted.v {
+		return nil // Only want deletions
+	}
+
+	if exts != nil {
+		youngest.exts = exts.String()
+	}
+
+	return youngest
+}
+
+// futureDated returns true if the candidate is dated further in the future than the -skew
+// tolerance. A candidate within the tolerance is adjusted to an age of zero so that it
+// ranks as "now" rather than "fut".
+func (scn *scanner) futureDated(c *candidate) bool {
+	if c.age.seconds >= 0 {
+		return false
+	}
+	if -c.age.seconds <= scn.cfg.skew.seconds {
+		c.age.seconds = 0
+		return false
+	}
+
+	return true
+}
+
+// excludeFuture returns true if the candidate is future-dated and should be excluded from
+// ranking due to -ifuture or -pfuture. In the latter case a copy of the candidate is
+// conditionally added to futureCandidates for printing separately.
+func (scn *scanner) excludeFuture(c *candidate) bool {
+	if !scn.futureDated(c) || !(scn.cfg.ignoreFuture.v || scn.cfg.printFuture.v) {
+		return false
+	}
+	if scn.cfg.printFuture.v {
+		fc := *c
+		scn.futureCandidates.addMaybe(&fc)
 	}
-	defer f.Close()
 
-	fi, err := scn.fsf(f)
+	return true
+}
+
+// getFileInfo returns the fs.FileInfo of name within tree. Symlinks are followed.
+func (scn *scanner) getFileInfo(tree *scanTree, name string) (fs.FileInfo, error) {
+	fi, err := fs.Stat(tree.fsys, name)
 	if err != nil {
-		return nil, fmt.Errorf("Getting File Stat: %w", err)
+		return nil, fmt.Errorf("Getting File Info: %w", tree.pathError(name, err))
 	}
 
 	return fi, nil
 }
+
+// readDir returns the directory entries of name within tree.
+func (scn *scanner) readDir(tree *scanTree, name string) ([]fs.DirEntry, error) {
+	dirents, err := fs.ReadDir(tree.fsys, name)
+	if err != nil {
+		return dirents, tree.pathError(name, err)
+	}
+
+	return dirents, nil
+}
+
+// descendRoot starts the scan of a command line path. A path which names a supported
+// archive or an mtree specification is loaded as a virtual tree and scanned from the top
+// of that tree. Similarly with -git, a directory is loaded as a virtual tree of the files
+// tracked by git. Loading is subject to concurrency control as it is i/o intensive. All
+// other paths are scanned via os.DirFS.
+func (scn *scanner) descendRoot(path string) {
+	fi, err := os.Stat(path)
+	if err == nil && fi.IsDir() && scn.cfg.gitHistory.v {
+		scn.descendLoaded("Git Repository", path, path, loadGit)
+		return
+	}
+	if err != nil || fi.IsDir() { // Let scan() report any errors
+		scn.descend(&scanTree{fsys: os.DirFS(path), prefix: path, live: true}, 0, ".")
+		return
+	}
+
+	load := archiveLoader(path)
+	if load == nil && fi.Mode().IsRegular() && isMtree(path) {
+		load = loadMtree
+	}
+	if load == nil { // os.DirFS can't say this as clearly as we can
+		atomic.AddUint32(&scn.errorCount, 1)
+		if !scn.cfg.suppressErrors.v {
+			fmt.Fprintln(scn.stderr, "Error:", path, "is not a directory")
+		}
+		return
+	}
+
+	scn.descendLoaded("Archive", path, path+archiveSeparator, load)
+}
+
+// descendLoaded loads path as a virtual tree in a separate goroutine then scans it with
+// all entries displayed relative to prefix. The what string describes the type of tree
+// in error messages.
+func (scn *scanner) descendLoaded(what, path, prefix string, load archiveLoadFunc) {
+	scn.wg.Add(1)
+	go func() {
+		defer scn.wg.Done()
+		scn.cc.start()
+		fsys, err := load(path)
+		scn.cc.done()
+		if err != nil {
+			atomic.AddUint32(&scn.errorCount, 1)
+			if !scn.cfg.suppressErrors.v {
+				fmt.Fprintln(scn.stderr, "Error: Loading", what, err)
+			}
+			return
+		}
+		scn.descendFS(fsys, prefix)
+	}()
+}
+
+// descendFS starts the scan of any fs.FS from its root with all paths displayed, and
+// filtered, relative to prefix. This is the entry point for scanning any file system
+// which is not a command line path, such as an embed.FS or fstest.MapFS.
+func (scn *scanner) descendFS(fsys fs.FS, prefix string) {
+	scn.descend(&scanTree{fsys: fsys, prefix: prefix}, 0, ".")
+}
+
+// scanTree identifies the file system being scanned. Names within the tree are fs.FS
+// names which are displayed, and filtered, with prefix prepended using the OS path
+// separator. For command line directories the prefix is the directory path so the
+// displayed path is exactly the native path.
+type scanTree struct {
+	fsys   fs.FS
+	prefix string
+	live   bool // A native directory which can be watched for changes
+}
+
+// join returns the fs.FS name of base within the directory dir.
+func (t *scanTree) join(dir, base string) string {
+	if dir == "." {
+		return base
+	}
+
+	return dir + "/" + base
+}
+
+// display returns the path of name for printing and filtering purposes.
+func (t *scanTree) display(name string) string {
+	return filepath.Join(t.prefix, filepath.FromSlash(name))
+}
+
+// pathError replaces the fs.FS name in a *fs.PathError with the display path as fs.FS
+// implementations such as os.DirFS only report the name relative to their root, which
+// is meaningless to the user.
+func (t *scanTree) pathError(name string, err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return &fs.PathError{Op: pe.Op, Path: t.display(name), Err: pe.Err}
+	}
+
+	return err
+}
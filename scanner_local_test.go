@@ -20,7 +20,7 @@ func TestScannerBasic(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	scn.descend(0, "testdata/scan10")
+	scn.descendRoot("testdata/scan10")
 	scn.wait()
 
 	if len(can.cf) != 1 {
@@ -7,8 +7,10 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -50,6 +52,36 @@ func (td *testDir) readDir() ([]fs.DirEntry, error) {
 	return td.dirents, td.err
 }
 
+// tree returns a scanTree whose root directory is the real dirName but whose directory
+// entries are those of the testDir.
+func (td *testDir) tree(dirName string) *scanTree {
+	return &scanTree{fsys: &testFS{FS: os.DirFS(dirName), td: td}, prefix: dirName}
+}
+
+// testFS wraps an fs.FS to inject directory entries and errors which are otherwise hard
+// to synthesize with testdata directories.
+type testFS struct {
+	fs.FS
+	td      *testDir // If set, supplies ReadDir results
+	statErr error    // If set, returned by Stat
+}
+
+func (tfs *testFS) Stat(name string) (fs.FileInfo, error) {
+	if tfs.statErr != nil {
+		return nil, tfs.statErr
+	}
+
+	return fs.Stat(tfs.FS, name)
+}
+
+func (tfs *testFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if tfs.td != nil {
+		return tfs.td.readDir()
+	}
+
+	return fs.ReadDir(tfs.FS, name)
+}
+
 func testScannerSetup(cfg *config, stderr io.Writer, ccCount int) (*scanner, *candidates, error) {
 	cfg.setInternalDefaults()
 	err := cfg.compile()
@@ -92,8 +124,7 @@ func TestScannerReaddir(t *testing.T) {
 	}
 
 	td := testDir{err: errors.New("Error One")}
-	scn.rdf = func(name string) ([]fs.DirEntry, error) { return td.readDir() }
-	scn.scan(0, "testdata")
+	scn.scan(td.tree("testdata"), 0, ".")
 	scn.wait()
 
 	if scn.stats.errorCount != 1 || scn.stats.ignoreCount != 1 || scn.stats.sum() != 2 {
@@ -118,8 +149,7 @@ func TestScannerEmpty(t *testing.T) {
 	}
 
 	td := testDir{}
-	scn.rdf = func(name string) ([]fs.DirEntry, error) { return td.readDir() }
-	scn.scan(0, "testdata")
+	scn.scan(td.tree("testdata"), 0, ".")
 	scn.wait()
 
 	if scn.stats.ignoreCount != 1 || scn.stats.sum() != 1 {
@@ -139,8 +169,7 @@ func TestScannerDirents(t *testing.T) {
 
 	td := testDir{}
 	td.dirents = append(td.dirents, &testDirEntry{err: errors.New("td error one")})
-	scn.rdf = func(name string) ([]fs.DirEntry, error) { return td.readDir() }
-	scn.scan(0, "testdata")
+	scn.scan(td.tree("testdata"), 0, ".")
 	scn.wait()
 
 	exp := stderr.String()
@@ -163,8 +192,7 @@ func TestScannerIrregular(t *testing.T) {
 	tde := &testDirEntry{fileMode: fs.ModeIrregular,
 		fileInfo: &testFileInfo{name: "testfile", mode: fs.ModeIrregular}}
 	td.dirents = append(td.dirents, tde)
-	scn.rdf = func(name string) ([]fs.DirEntry, error) { return td.readDir() }
-	scn.scan(0, "testdata")
+	scn.scan(td.tree("testdata"), 0, ".")
 	scn.wait()
 
 	if scn.stats.errorCount != 0 || scn.stats.otherCount != 1 {
@@ -187,8 +215,7 @@ func TestScannerCandidate(t *testing.T) {
 	td := testDir{}
 	tde := &testDirEntry{fileInfo: &testFileInfo{name: "testfileIgnore"}}
 	td.dirents = append(td.dirents, tde)
-	scn.rdf = func(name string) ([]fs.DirEntry, error) { return td.readDir() }
-	scn.scan(0, "testdata")
+	scn.scan(td.tree("testdata"), 0, ".")
 	scn.wait()
 
 	if scn.stats.errorCount != 0 || scn.stats.ignoreCount != 2 {
@@ -228,7 +255,7 @@ func TestScannerMaxDepth(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		scn.descend(0, "testdata/maxdir")
+		scn.descendRoot("testdata/maxdir")
 		scn.wait()
 
 		got := len(can.cf)
@@ -262,8 +289,7 @@ func TestScannerMaxAge(t *testing.T) {
 		tde := &testDirEntry{fileInfo: &testFileInfo{name: tc.name, modTime: now.Add(tc.offset)}}
 		td.dirents = append(td.dirents, tde)
 	}
-	scn.rdf = func(name string) ([]fs.DirEntry, error) { return td.readDir() }
-	scn.scan(0, ".")
+	scn.scan(td.tree("."), 0, ".")
 	scn.wait()
 
 	if len(can.cf) != 1 {
@@ -282,7 +308,7 @@ func TestScannerScanError(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	scn.scan(0, "testdata/noexist")
+	scn.scan(&scanTree{fsys: os.DirFS("testdata/noexist"), prefix: "testdata/noexist"}, 0, ".")
 	scn.wait()
 	if scn.stats.errorCount != 1 || scn.stats.sum() != 1 {
 		t.Error("Expected error,sum == '1 1' not",
@@ -306,7 +332,7 @@ func TestScannerScanYoungest(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	scn.descend(0, "testdata")
+	scn.descendRoot("testdata")
 	scn.wait()
 	if scn.stats.dirCount != 1 || scn.stats.sum() != 1 {
 		t.Error("Expected dir,sum == '1 1' not",
@@ -330,7 +356,7 @@ func TestScannerIgnoreTypes(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	scn.descend(0, "testdata")
+	scn.descendRoot("testdata")
 	scn.wait()
 	if scn.stats.dirCount != scn.stats.ignoreCount {
 		t.Error("Expected dir == ignore, not",
@@ -348,10 +374,6 @@ func TestScannerIgnoreTypes(t *testing.T) {
 	}
 }
 
-func testFsfError(f *os.File) (fs.FileInfo, error) {
-	return nil, errors.New("fsf Failed")
-}
-
 // Test for fstat() failure
 func TestScannerStatFail(t *testing.T) {
 	var stderr bytes.Buffer
@@ -361,13 +383,213 @@ func TestScannerStatFail(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	scn.fsf = testFsfError
-	scn.descend(0, "testdata")
+	scn.descendFS(&testFS{FS: os.DirFS("testdata"), statErr: errors.New("stat Failed")}, "testdata")
 	scn.wait()
 
 	got := stderr.String()
-	exp := "Getting File Stat"
+	exp := "Getting File Info: stat Failed"
 	if !strings.Contains(got, exp) {
 		t.Error("Expected", exp, "got", got)
 	}
 }
+
+// Test that a directory with no younger entries is marked as deleted and that deleted
+// directories are subject to -itypes and -pdeleted.
+func TestScannerDeleted(t *testing.T) {
+	testCases := []struct {
+		itypes    string
+		pdeleted  bool
+		younger   bool // Populate directory with a younger entry
+		expect    int
+		isDeleted bool
+	}{
+		{fTypeTemporary, false, false, 1, true},
+		{fTypeTemporary + "," + fTypeDeleted, false, false, 0, false},
+		{fTypeDir, false, false, 0, false}, // Default of not seeding with directory
+		{fTypeDir, true, false, 1, true},   // -pdeleted forces seeding
+		{fTypeDir, true, true, 0, false},   // but not when there is a younger entry
+		{fTypeTemporary, false, true, 1, false},
+	}
+
+	for ix, tc := range testCases {
+		var stderr bytes.Buffer
+		cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+			func() (string, error) { return "", nil })
+		cfg.ignoreTypes.v = tc.itypes
+		cfg.printDeleted.v = tc.pdeleted
+		scn, can, err := testScannerSetup(cfg, &stderr, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		td := testDir{}
+		if tc.younger {
+			tde := &testDirEntry{fileInfo: &testFileInfo{name: "young", modTime: time.Now()}}
+			td.dirents = append(td.dirents, tde)
+		}
+		scn.scan(td.tree("testdata"), 0, ".")
+		scn.wait()
+
+		if len(can.cf) != tc.expect {
+			t.Error(ix, "Expected", tc.expect, "candidates, got", len(can.cf))
+			continue
+		}
+		if tc.expect > 0 && can.cf[0].deleted != tc.isDeleted {
+			t.Error(ix, "Expected deleted to be", tc.isDeleted, can.cf[0])
+		}
+	}
+}
+
+// Test -skew tolerance and the exclusion of future-dated entries with -ifuture and
+// -pfuture.
+func TestScannerFuture(t *testing.T) {
+	testCases := []struct {
+		skew     int64
+		ifuture  bool
+		pfuture  bool
+		expect   string // Path of youngest candidate
+		seconds  int64  // and its age
+		inFuture int    // Number of future candidates
+	}{
+		{0, false, false, "bogus", -3 * day, 0}, // Default is to let future entries win
+		{2 * hour, false, false, "bogus", -3 * day, 0},
+		{2 * hour, true, false, "skewed", 0, 0}, // Within tolerance becomes zero
+		{0, true, false, "recent", 60, 0},       // No tolerance so only the past counts
+		{2 * hour, false, true, "skewed", 0, 1}, // Exclude and collect
+		{0, false, true, "recent", 60, 2},
+	}
+
+	for ix, tc := range testCases {
+		var stderr bytes.Buffer
+		cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+			func() (string, error) { return "", nil })
+		cfg.skew.seconds = tc.skew
+		cfg.ignoreFuture.v = tc.ifuture
+		cfg.printFuture.v = tc.pfuture
+		scn, can, err := testScannerSetup(cfg, &stderr, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		td := testDir{}
+		for _, e := range []struct {
+			name   string
+			offset time.Duration
+		}{
+			{"recent", -1 * time.Minute},
+			{"skewed", time.Hour},
+			{"bogus", 72 * time.Hour},
+		} {
+			tde := &testDirEntry{fileInfo: &testFileInfo{name: e.name,
+				modTime: scn.baseTime.Add(e.offset)}}
+			td.dirents = append(td.dirents, tde)
+		}
+		scn.scan(td.tree("."), 0, ".")
+		scn.wait()
+
+		if len(can.cf) != 1 {
+			t.Fatal(ix, "Expected one candidate got", len(can.cf))
+		}
+		if can.cf[0].path != tc.expect || can.cf[0].age.seconds != tc.seconds {
+			t.Error(ix, "Expected", tc.expect, tc.seconds, "got", can.cf[0])
+		}
+		if len(scn.futureCandidates.cf) != tc.inFuture {
+			t.Error(ix, "Expected", tc.inFuture, "future candidates, got",
+				len(scn.futureCandidates.cf))
+		}
+	}
+}
+
+// Test that overlapping and duplicate roots are only scanned once.
+func TestScannerSetRoots(t *testing.T) {
+	fi, err := os.Stat("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasID := getStatDetails(fi).hasID
+
+	testCases := []struct {
+		roots      []string
+		expectDirs int // Roots remaining after setRoots
+		expectCf   int
+		needsID    bool
+	}{
+		{[]string{"testdata/maxdir"}, 1, 3, false},
+		{[]string{"testdata/maxdir", "testdata/maxdir"}, 1, 3, false},
+		{[]string{"testdata/maxdir", "testdata/maxdir/two"}, 2, 3, true},
+		{[]string{"testdata/maxdir/two", "testdata/maxdir"}, 2, 3, true},
+		{[]string{"testdata/maxdir", "testdata/../testdata/maxdir/."}, 1, 3, true},
+		{[]string{"testdata/maxdir", "testdata/noexist"}, 2, 3, false},
+	}
+
+	for ix, tc := range testCases {
+		if tc.needsID && !hasID {
+			continue
+		}
+		var stderr bytes.Buffer
+		cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+			func() (string, error) { return "", nil })
+		cfg.suppressErrors.v = true
+		scn, can, err := testScannerSetup(cfg, &stderr, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots := scn.setRoots(tc.roots)
+		if len(roots) != tc.expectDirs {
+			t.Error(ix, "Expected", tc.expectDirs, "roots, got", roots)
+		}
+		for _, dirName := range roots {
+			scn.descendRoot(dirName)
+		}
+		scn.wait()
+
+		if len(can.cf) != tc.expectCf {
+			t.Error(ix, "Expected", tc.expectCf, "candidates, got", len(can.cf))
+			for _, c := range can.cf {
+				t.Log(c.path)
+			}
+		}
+	}
+}
+
+// Test that any fs.FS can be scanned and that ignore filters apply to the displayed path.
+func TestScannerMapFS(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+		func() (string, error) { return "", nil })
+	cfg.ignoreContains.v = "synthetic/build"
+	scn, can, err := testScannerSetup(cfg, &stderr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(d time.Duration) *fstest.MapFile {
+		return &fstest.MapFile{ModTime: scn.baseTime.Add(-d)}
+	}
+	mfs := fstest.MapFS{
+		"src/main.go":       at(2 * time.Hour),
+		"src/util.go":       at(3 * time.Hour),
+		"src/.git/index":    at(time.Second), // Ignored by default bases
+		"build/output.bin":  at(time.Minute), // Ignored by -icontains
+		"docs/guide/ch1.md": at(time.Duration(5*day) * time.Second),
+	}
+	scn.descendFS(mfs, "synthetic")
+	scn.wait()
+	can.sortAscending()
+
+	exp := []struct {
+		path    string
+		seconds int64
+	}{
+		{filepath.Join("synthetic", "src", "main.go"), 2 * hour},
+		{filepath.Join("synthetic", "docs", "guide", "ch1.md"), 5 * day},
+	}
+	if len(can.cf) != len(exp) {
+		t.Fatal("Expected", len(exp), "candidates, got", len(can.cf), stderr.String())
+	}
+	for ix, e := range exp {
+		if can.cf[ix].path != e.path || can.cf[ix].age.seconds != e.seconds {
+			t.Error(ix, "Expected", e, "got", can.cf[ix])
+		}
+	}
+}
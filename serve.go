@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	serveRescanParam = "rescan"         // Query parameter which forces a rescan
+	serveRescanMin   = 10 * time.Second // Forced rescans of younger results are ignored
+	serveCacheMax    = 32               // Maximum distinct option sets with cached results
+	serveErrorsMax   = 100              // Maximum scan errors included in a result
+)
+
+// serveOptions are the only options which clients can change. Others are of concern to
+// whoever runs the server or could be used to probe the system, such as "user" revealing
+// which accounts exist.
+var serveOptions = []string{"age", "count", "depth", "ibases", "icontains", "iregexes", "itypes"}
+
+// serveCandidate is the JSON form of a candidate.
+type serveCandidate struct {
+	Age     string    `json:"age"`     // As printed
+	Seconds int64     `json:"seconds"` // Age in seconds
+	Type    string    `json:"type"`
+	Path    string    `json:"path"`
+	Dir     string    `json:"dir"` // As printed by -pdirname
+	ModTime time.Time `json:"mtime"`
+	Owner   string    `json:"owner,omitempty"` // With -powner
+	Exts    string    `json:"exts,omitempty"`  // With -pexts
+}
+
+type serveStats struct {
+	Elapsed float64 `json:"elapsed"` // Seconds
+	Found   int     `json:"found"`
+	Dirs    uint32  `json:"dirs"`
+	Files   uint32  `json:"files"`
+	Others  uint32  `json:"others"`
+	Ignored uint32  `json:"ignored"`
+	Errors  uint32  `json:"errors"`
+}
+
+// serveResult is the outcome of a scan as returned by the JSON API.
+type serveResult struct {
+	Time       time.Time         `json:"time"` // Base time of the scan
+	Options    map[string]string `json:"options"`
+	Candidates []serveCandidate  `json:"candidates"`
+	Future     []serveCandidate  `json:"future,omitempty"` // With -pfuture
+	Stats      serveStats        `json:"stats"`
+	Errors     []string          `json:"errors,omitempty"`
+}
+
+// server serves scan results over HTTP. Results are cached for each distinct set of
+// options and rescanned once they are older than the rescan interval or when a client
+// asks. Scans are serialized so that clients cannot overload the file system, but cached
+// results are still returned while a scan is in progress.
+type server struct {
+	newConfig func(query url.Values) (*config, error) // Command line config plus query
+	scan      func(cfg *config, stderr io.Writer) *scanner
+	interval  time.Duration // Results older than this are rescanned. Zero means never
+	rescanMin time.Duration // Forced rescans of results younger than this are ignored
+
+	scanMu sync.Mutex // Serializes scans
+	mu     sync.Mutex // Protects cache
+	cache  map[string]*serveResult
+}
+
+func newServer(newConfig func(url.Values) (*config, error), scan func(*config, io.Writer) *scanner,
+	interval time.Duration) *server {
+	return &server{
+		newConfig: newConfig,
+		scan:      scan,
+		interval:  interval,
+		rescanMin: serveRescanMin,
+		cache:     make(map[string]*serveResult),
+	}
+}
+
+// setQuery applies the query parameters to the config with the same semantics as the
+// equivalent command line options, thus a leading "+" appends to a Comma-String. A
+// parameter given multiple times is applied in order. Only serveOptions are accepted.
+func (cfg *config) setQuery(query url.Values) error {
+	options := cfg.optionMap()
+	for name, values := range query {
+		fv, ok := options[name]
+		if !ok || !slices.Contains(serveOptions, name) {
+			return fmt.Errorf("Unknown option '%s'", name)
+		}
+		for _, v := range values {
+			err := fv.Set(v)
+			if err != nil {
+				return fmt.Errorf("Option '%s': %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// handler returns the handler for all endpoints:
+//
+//	/         HTML page
+//	/api/scan JSON serveResult
+func (srv *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/scan", srv.handleScan)
+	mux.HandleFunc("/", srv.handlePage)
+
+	return mux
+}
+
+// result returns the cached result for the options in query or a new result if the
+// cached result is stale or if the query contains serveRescanParam. A forced rescan is
+// ignored if the cached result is younger than rescanMin so that clients cannot keep the
+// server scanning.
+func (srv *server) result(query url.Values) (*serveResult, error) {
+	options := make(url.Values)
+	for name, values := range query {
+		if name != serveRescanParam {
+			options[name] = values
+		}
+	}
+	_, force := query[serveRescanParam]
+
+	return srv.lookup(options, force, srv.rescanMin)
+}
+
+// lookup returns the cached result for options unless it is stale, or unless force is
+// set and the result is at least minAge old, in which case a new scan is made. Requests
+// which arrive while a scan is in progress wait for it and use its result if it
+// satisfies them rather than scanning again.
+func (srv *server) lookup(options url.Values, force bool, minAge time.Duration) (*serveResult, error) {
+	cfg, err := srv.newConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	key := options.Encode() // Sorted by name, so identical options share a result
+	requested := time.Now()
+	if res := srv.cached(key, force, minAge); res != nil {
+		return res, nil
+	}
+
+	srv.scanMu.Lock()
+	defer srv.scanMu.Unlock()
+	srv.mu.Lock()
+	res, ok := srv.cache[key]
+	srv.mu.Unlock()
+	if ok && !res.Time.Before(requested) { // Scanned while waiting
+		return res, nil
+	}
+
+	var stderr bytes.Buffer
+	start := time.Now()
+	scn := srv.scan(cfg, &stderr)
+	res = scn.newServeResult(time.Since(start), &stderr)
+	res.Options = make(map[string]string)
+	for name, values := range options {
+		res.Options[name] = strings.Join(values, ",")
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.cache) >= serveCacheMax { // Evict the oldest
+		var oldest string
+		for k, r := range srv.cache {
+			if len(oldest) == 0 || r.Time.Before(srv.cache[oldest].Time) {
+				oldest = k
+			}
+		}
+		delete(srv.cache, oldest)
+	}
+	srv.cache[key] = res
+
+	return res, nil
+}
+
+// cached returns the cached result for key if it can be used, otherwise nil.
+func (srv *server) cached(key string, force bool, minAge time.Duration) *serveResult {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	res, ok := srv.cache[key]
+	if !ok {
+		return nil
+	}
+	since := time.Since(res.Time)
+	if force && since >= minAge || srv.interval > 0 && since >= srv.interval {
+		return nil
+	}
+
+	return res
+}
+
+// refresh rescans the default options each interval so that the common request is
+// answered without waiting for a scan. Returns when stop is closed.
+func (srv *server) refresh(stop <-chan struct{}) {
+	if srv.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(srv.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			srv.lookup(url.Values{}, true, 0)
+		}
+	}
+}
+
+// newServeResult converts the candidates and stats of the scanner into a serveResult.
+// Scan errors written to stderr are included.
+func (scn *scanner) newServeResult(elapsed time.Duration, stderr *bytes.Buffer) *serveResult {
+	res := &serveResult{Time: scn.baseTime,
+		Candidates: scn.serveCandidates(scn.allCandidates, (*age).compactString),
+		Stats: serveStats{Elapsed: elapsed.Seconds(), Found: len(scn.allCandidates.cf),
+			Dirs: scn.dirCount, Files: scn.fileCount, Others: scn.otherCount,
+			Ignored: scn.ignoreCount, Errors: scn.errorCount}}
+	if scn.cfg.printFuture.v {
+		res.Future = scn.serveCandidates(scn.futureCandidates, (*age).futureString)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+		if len(line) > 0 && len(res.Errors) < serveErrorsMax {
+			res.Errors = append(res.Errors, line)
+		}
+	}
+
+	return res
+}
+
+func (scn *scanner) serveCandidates(can *candidates, ageString func(*age) string) []serveCandidate {
+	names := make(ownerNames)
+	scs := make([]serveCandidate, 0, len(can.cf))
+	for _, c := range can.cf {
+		sc := serveCandidate{Age: ageString(&c.age), Seconds: c.age.seconds, Type: c.fType(),
+			Path: filepath.Clean(c.path), Dir: c.dirName(), ModTime: c.modTime}
+		if scn.cfg.printOwner.v {
+			sc.Owner = names.name(c.owner)
+		}
+		if scn.cfg.printExts.v {
+			sc.Exts = c.exts
+		}
+		scs = append(scs, sc)
+	}
+
+	return scs
+}
+
+func (srv *server) handleScan(w http.ResponseWriter, r *http.Request) {
+	res, err := srv.result(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	enc.Encode(res)
+}
+
+func (srv *server) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	for name, values := range query { // Unused form fields are empty
+		if len(strings.Join(values, "")) == 0 && name != serveRescanParam {
+			query.Del(name)
+		}
+	}
+	res, err := srv.result(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query.Del(serveRescanParam)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	servePage.Execute(w, struct {
+		Name   string
+		JSON   template.URL
+		Form   []serveField
+		Result *serveResult
+	}{Name, template.URL("/api/scan?" + query.Encode()), serveFields(query), res})
+}
+
+// serveField is an option which can be changed on the HTML page.
+type serveField struct {
+	Name  string
+	Value string
+}
+
+func serveFields(query url.Values) (fields []serveField) {
+	for _, name := range serveOptions {
+		fields = append(fields, serveField{name, query.Get(name)})
+	}
+
+	return
+}
+
+var servePage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} - active directories</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.1em 0.6em; text-align: left; }
+td.age { text-align: right; }
+tr:nth-child(even) { background: #f0f0f0; }
+.path { font-family: monospace; }
+form input[type=text] { width: 6em; }
+</style>
+</head>
+<body>
+<h1>Active directories</h1>
+<form method="get" action="/">
+{{range .Form}}<label>{{.Name}} <input type="text" name="{{.Name}}" value="{{.Value}}"></label>
+{{end}}<input type="submit" value="Apply">
+<button type="submit" name="rescan" value="1">Rescan</button>
+</form>
+{{with .Result}}
+<p>Scanned {{.Time.Format "2006-01-02 15:04:05"}} in {{printf "%.1f" .Stats.Elapsed}}s:
+{{.Stats.Found}} found, {{.Stats.Dirs}} directories, {{.Stats.Files}} files,
+{{.Stats.Errors}} errors.
+<a href="{{$.JSON}}">JSON</a></p>
+<table>
+<tr><th>Age</th><th>Type</th><th>Path</th></tr>
+{{range .Candidates}}<tr><td class="age">{{.Age}}</td><td>{{.Type}}</td><td class="path">{{.Path}}</td></tr>
+{{end}}</table>
+{{if .Errors}}<h2>Errors</h2>
+<ul>
+{{range .Errors}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+// listenAndServe serves on addr until stop is closed.
+func (srv *server) listenAndServe(addr string, stop <-chan struct{}) error {
+	hs := &http.Server{Addr: addr, Handler: srv.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go srv.refresh(stop)
+	go func() {
+		<-stop
+		hs.Close()
+	}()
+	err := hs.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
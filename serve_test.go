@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testServeConfig(query url.Values) (*config, error) {
+	fs := flag.NewFlagSet(Name, flag.ContinueOnError)
+	cfg := newConfig(fs, func() (string, error) { return "", nil })
+	cfg.loadDefaults()
+	cfg.setFlags()
+	err := cfg.setQuery(query)
+	if err == nil {
+		err = cfg.compile()
+	}
+	return cfg, err
+}
+
+// testServer returns a server which scans root and counts its scans. Forced rescans are
+// never ignored.
+func testServer(t *testing.T, root string, interval time.Duration) (*httptest.Server, *int) {
+	scans := new(int)
+	scan := func(cfg *config, stderr io.Writer) *scanner {
+		*scans++
+		return scanAll(cfg, time.Now(), []string{root}, nil, "", stderr)
+	}
+	srv := newServer(testServeConfig, scan, interval)
+	srv.rescanMin = 0
+	ts := httptest.NewServer(srv.handler())
+	t.Cleanup(ts.Close)
+
+	return ts, scans
+}
+
+func testGet(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	return resp.StatusCode, string(body)
+}
+
+func TestServe(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	for _, d := range []string{"old", "<b>new"} {
+		os.Mkdir(filepath.Join(root, d), 0700)
+		os.WriteFile(filepath.Join(root, d, "file"), nil, 0600)
+	}
+	os.Chtimes(filepath.Join(root, "old", "file"), old, old)
+	ts, scans := testServer(t, root, 0)
+
+	code, body := testGet(t, ts.URL+"/api/scan")
+	var res serveResult
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &res) != nil {
+		t.Fatal("Bad response", code, body)
+	}
+	if len(res.Candidates) != 2 || res.Candidates[0].Dir != filepath.Join(root, "<b>new") ||
+		res.Stats.Found != 2 || res.Stats.Files != 2 {
+		t.Error("Unexpected result", res)
+	}
+
+	// Results are cached until a rescan is requested
+	testGet(t, ts.URL+"/api/scan")
+	if *scans != 1 {
+		t.Error("Expected cached result", *scans)
+	}
+	testGet(t, ts.URL+"/api/scan?rescan=1")
+	if *scans != 2 {
+		t.Error("Expected rescan", *scans)
+	}
+
+	// Query parameters map to options, including "+" appends
+	testCases := []struct {
+		query string
+		code  int
+		found int
+		body  string
+	}{
+		{"age=1h", http.StatusOK, 1, ""},
+		{"count=1&rescan", http.StatusOK, 1, ""},
+		{"ibases=%2Bold", http.StatusOK, 1, ""},
+		{"icontains=new&icontains=%2Bold", http.StatusOK, 0, ""},
+		{"age=1x", http.StatusBadRequest, 0, "invalid unit"},
+		{"nosuch=1", http.StatusBadRequest, 0, "Unknown option 'nosuch'"},
+		{"scanners=1", http.StatusBadRequest, 0, "Unknown option 'scanners'"},
+		{"user=root", http.StatusBadRequest, 0, "Unknown option 'user'"},
+		{"q=true", http.StatusBadRequest, 0, "Unknown option 'q'"},
+	}
+	for ix, tc := range testCases {
+		code, body := testGet(t, ts.URL+"/api/scan?"+tc.query)
+		if code != tc.code {
+			t.Error(ix, "Expected code", tc.code, "got", code, body)
+			continue
+		}
+		if code != http.StatusOK {
+			if !strings.Contains(body, tc.body) {
+				t.Error(ix, "Expected body with", tc.body, "got", body)
+			}
+			continue
+		}
+		var res serveResult
+		json.Unmarshal([]byte(body), &res)
+		if res.Stats.Found != tc.found {
+			t.Error(ix, "Expected found", tc.found, "got", res.Stats.Found)
+		}
+	}
+
+	// HTML page escapes paths and ignores empty form fields
+	code, body = testGet(t, ts.URL+"/?age=&count=5")
+	if code != http.StatusOK || !strings.Contains(body, "&lt;b&gt;new") ||
+		strings.Contains(body, "<b>new") || !strings.Contains(body, `href="/api/scan?count=5"`) {
+		t.Error("Unexpected page", code, body)
+	}
+	if code, _ := testGet(t, ts.URL+"/nosuch"); code != http.StatusNotFound {
+		t.Error("Expected not found", code)
+	}
+}
+
+func TestServeInterval(t *testing.T) {
+	ts, scans := testServer(t, t.TempDir(), time.Nanosecond)
+	testGet(t, ts.URL+"/api/scan")
+	testGet(t, ts.URL+"/api/scan")
+	if *scans != 2 {
+		t.Error("Stale results should be rescanned", *scans)
+	}
+}
+
+func TestServeRescanLimit(t *testing.T) {
+	var scans int
+	scan := func(cfg *config, stderr io.Writer) *scanner {
+		scans++
+		return scanAll(cfg, time.Now(), []string{t.TempDir()}, nil, "", stderr)
+	}
+	srv := newServer(testServeConfig, scan, 0)
+	srv.result(url.Values{})
+	srv.result(url.Values{serveRescanParam: nil})
+	if scans != 1 {
+		t.Error("Forced rescan of a young result should be ignored", scans)
+	}
+	srv.rescanMin = time.Nanosecond
+	srv.result(url.Values{serveRescanParam: nil})
+	if scans != 2 {
+		t.Error("Forced rescan of an old result should scan", scans)
+	}
+}
+
+// TestServeConcurrent checks that cached results are returned while a scan is in progress
+// and that requests which wait for a scan share its result.
+func TestServeConcurrent(t *testing.T) {
+	root := t.TempDir()
+	var scans atomic.Int32
+	block := make(chan struct{})
+	scan := func(cfg *config, stderr io.Writer) *scanner {
+		if scans.Add(1) > 1 {
+			<-block
+		}
+		return scanAll(cfg, time.Now(), []string{root}, nil, "", stderr)
+	}
+	srv := newServer(testServeConfig, scan, 0)
+	cached, _ := srv.result(url.Values{})
+
+	results := make(chan *serveResult, 2)
+	for _, age := range []string{"1h", "1h"} {
+		go func() {
+			res, _ := srv.result(url.Values{"age": {age}})
+			results <- res
+		}()
+	}
+	for scans.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if res, _ := srv.result(url.Values{}); res != cached {
+		t.Error("Cached result should not wait for the scan")
+	}
+	close(block)
+	first, second := <-results, <-results
+	if first != second || scans.Load() != 2 {
+		t.Error("Waiting requests should share a scan", scans.Load())
+	}
+}
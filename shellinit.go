@@ -0,0 +1,343 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// shellNames are the shells supported by -shell-init
+var shellNames = []string{"bash", "fish", "zsh"}
+
+// shellArg describes how the value of a flag is completed.
+type shellArg int
+
+const (
+	shellArgNone  shellArg = iota // Flag takes no value
+	shellArgAny                   // Free-form value with no completion
+	shellArgFile                  // File name
+	shellArgAge                   // Digits followed by an age unit
+	shellArgTypes                 // Comma separated -itypes letters
+	shellArgUser                  // User name
+	shellArgGroup                 // Group name
+	shellArgShell                 // One of shellNames
+)
+
+// shellArgKinds maps flags with completable values to their shellArg. Any other flag which
+// takes a value is shellArgAny.
+var shellArgKinds = map[string]shellArg{
+	"age":           shellArgAge,
+	"diff":          shellArgFile,
+	"expect-active": shellArgAge,
+	"expect-quiet":  shellArgAge,
+	"from":          shellArgFile,
+	"group":         shellArgGroup,
+	"itypes":        shellArgTypes,
+	"listing":       shellArgFile,
+	"save":          shellArgFile,
+	"shell-init":    shellArgShell,
+	"skew":          shellArgAge,
+	"user":          shellArgUser,
+}
+
+// shellFlag is a flag as needed to generate completions.
+type shellFlag struct {
+	name   string // As typed, thus with leading dashes
+	bare   string // Without leading dashes
+	usage  string
+	arg    shellArg
+	always bool // Flag only accepts a value via "=" - see optionalFlag
+}
+
+// shellFlags returns every flag in fs in a form ready to generate completions. Single
+// letter flags are presented with one dash and all others with two, as in the manpage.
+func shellFlags(fs *flag.FlagSet) (flags []shellFlag) {
+	fs.VisitAll(func(f *flag.Flag) {
+		sf := shellFlag{name: "--" + f.Name, bare: f.Name, usage: f.Usage, arg: shellArgAny}
+		if len(f.Name) == 1 {
+			sf.name = "-" + f.Name
+		}
+		if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() {
+			sf.arg = shellArgNone
+			_, sf.always = f.Value.(*optionalFlag)
+		} else if kind, ok := shellArgKinds[f.Name]; ok {
+			sf.arg = kind
+		}
+		flags = append(flags, sf)
+	})
+
+	return
+}
+
+// shellAgeUnits returns the age units in ascending order of duration.
+func shellAgeUnits() (units []string) {
+	for unit := range ageUnitToSeconds {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return ageUnitToSeconds[units[i]] < ageUnitToSeconds[units[j]]
+	})
+
+	return
+}
+
+// shellTypes returns the valid -itypes letters in sorted order.
+func shellTypes() (types []string) {
+	for t := range validFTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	return
+}
+
+// printShellInit writes the shell integration for the named shell to out. This consists
+// of an "fcd" function which changes to the directory selected with -tui, an "fj"
+// function which changes to the directory found by -jump, a hook which records each
+// directory change with -visit, and completion definitions for every flag in fs.
+func printShellInit(out io.Writer, shell string, fs *flag.FlagSet) error {
+	flags := shellFlags(fs)
+	switch shell {
+	case "bash":
+		printBashInit(out, flags)
+	case "fish":
+		printFishInit(out, flags)
+	case "zsh":
+		printZshInit(out, flags)
+	default:
+		return fmt.Errorf("-shell-init '%s' must be one of %s", shell, strings.Join(shellNames, ", "))
+	}
+
+	return nil
+}
+
+// The preambles are the fixed parts of each script and are expanded by shellTemplate. They
+// define the fcd and fj functions along with the hook which records visits for -jump.
+const (
+	bashPreamble = `# {{.Name}} shell integration for bash. Load with: eval "$({{.Name}} -shell-init bash)"
+
+fcd() {
+	local dir
+	dir=$(command {{.Name}} -tui "$@") && [ -n "$dir" ] && cd -- "$dir"
+}
+
+fj() {
+	local dir
+	dir=$(command {{.Name}} -jump "$1" "${@:2}") && cd -- "$dir"
+}
+
+_{{.Name}}_visit() {
+	[[ $_{{.Name}}_pwd == "$PWD" ]] && return
+	_{{.Name}}_pwd=$PWD
+	command {{.Name}} -visit "$PWD" 2>/dev/null
+}
+[[ $PROMPT_COMMAND == *_{{.Name}}_visit* ]] || PROMPT_COMMAND="_{{.Name}}_visit${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+
+_{{.Name}}() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+	case $prev in
+`
+
+	fishPreamble = `# {{.Name}} shell integration for fish. Load with: {{.Name}} -shell-init fish | source
+
+function fcd --wraps {{.Name}} --description 'Change to a directory selected with {{.Name}} -tui'
+	set -l dir (command {{.Name}} -tui $argv); and test -n "$dir"; and cd -- $dir
+end
+
+function fj --description 'Change to the directory best matching a query with {{.Name}} -jump'
+	set -l dir (command {{.Name}} -jump $argv[1] $argv[2..]); and cd -- $dir
+end
+
+function __{{.Name}}_visit --on-variable PWD
+	command {{.Name}} -visit $PWD 2>/dev/null
+end
+
+function __{{.Name}}_ages
+	set -l n (commandline -ct)
+	string match -qr '^[1-9][0-9]*$' -- $n; or return
+	for unit in {{join .Units " "}}
+		echo $n$unit
+	end
+end
+
+function __{{.Name}}_types
+	set -l pre (string replace -r '[^,+]*$' '' -- (commandline -ct))
+	for type in {{join .Types " "}}
+		echo $pre$type
+	end
+end
+
+complete -c {{.Name}} -x -a '(__fish_complete_directories (commandline -ct))'
+`
+
+	zshPreamble = `# {{.Name}} shell integration for zsh. Load with: eval "$({{.Name}} -shell-init zsh)"
+
+fcd() {
+	local dir
+	dir=$(command {{.Name}} -tui "$@") && [[ -n $dir ]] && cd -- "$dir"
+}
+
+fj() {
+	local dir
+	dir=$(command {{.Name}} -jump "$1" "${@:2}") && cd -- "$dir"
+}
+
+_{{.Name}}_visit() {
+	command {{.Name}} -visit "$PWD" 2>/dev/null
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd _{{.Name}}_visit
+
+_{{.Name}}_ages() {
+	[[ $PREFIX == <1-> ]] && compadd -- ${PREFIX}{ {{- join .Units "," -}} }
+}
+
+_{{.Name}}() {
+	_arguments -s \
+`
+)
+
+// shellTemplate expands a preamble with the program name, age units and -itypes letters.
+func shellTemplate(out io.Writer, preamble string) {
+	tmpl := template.Must(template.New("preamble").
+		Funcs(template.FuncMap{"join": strings.Join}).Parse(preamble))
+	tmpl.Execute(out, struct {
+		Name  string
+		Units []string
+		Types []string
+	}{Name, shellAgeUnits(), shellTypes()})
+}
+
+func printBashInit(out io.Writer, flags []shellFlag) {
+	shellTemplate(out, bashPreamble)
+
+	patterns := make(map[shellArg][]string)
+	var names []string
+	for _, sf := range flags {
+		names = append(names, sf.name)
+		if sf.arg != shellArgNone {
+			patterns[sf.arg] = append(patterns[sf.arg], "-"+sf.bare, "--"+sf.bare)
+		}
+	}
+	replies := []struct {
+		arg   shellArg
+		reply string
+	}{
+		{shellArgAge, `[[ $cur =~ ^[1-9][0-9]*$ ]] && COMPREPLY=(` +
+			`$(printf "$cur%s " ` + strings.Join(shellAgeUnits(), " ") + `))`},
+		{shellArgTypes, `local pre=${cur%"${cur##*[,+]}"}` + "\n\t\t" +
+			`COMPREPLY=($(compgen -P "$pre" -W "` + strings.Join(shellTypes(), " ") +
+			`" -- "${cur##*[,+]}"))`},
+		{shellArgFile, `COMPREPLY=($(compgen -f -- "$cur"))`},
+		{shellArgUser, `COMPREPLY=($(compgen -u -- "$cur"))`},
+		{shellArgGroup, `COMPREPLY=($(compgen -g -- "$cur"))`},
+		{shellArgShell, `COMPREPLY=($(compgen -W "` + strings.Join(shellNames, " ") + `" -- "$cur"))`},
+		{shellArgAny, `:`},
+	}
+	for _, r := range replies {
+		if len(patterns[r.arg]) > 0 {
+			fmt.Fprintf(out, "\t%s)\n\t\t%s\n\t\treturn;;\n", strings.Join(patterns[r.arg], "|"), r.reply)
+		}
+	}
+
+	fmt.Fprintf(out, `	esac
+	if [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -d -- "$cur"))
+	fi
+}
+complete -o filenames -F _%s %s fcd
+`, strings.Join(names, " "), Name, Name)
+}
+
+func printFishInit(out io.Writer, flags []shellFlag) {
+	shellTemplate(out, fishPreamble)
+
+	for _, sf := range flags {
+		opt := "-l " + sf.bare
+		if len(sf.bare) == 1 {
+			opt = "-s " + sf.bare
+		}
+		var arg string
+		switch sf.arg {
+		case shellArgAny:
+			arg = " -x"
+		case shellArgFile:
+			arg = " -r -F"
+		case shellArgAge:
+			arg = " -x -a '(__" + Name + "_ages)'"
+		case shellArgTypes:
+			arg = " -x -a '(__" + Name + "_types)'"
+		case shellArgUser:
+			arg = " -x -a '(__fish_complete_users)'"
+		case shellArgGroup:
+			arg = " -x -a '(__fish_complete_groups)'"
+		case shellArgShell:
+			arg = " -x -a '" + strings.Join(shellNames, " ") + "'"
+		}
+		fmt.Fprintf(out, "complete -c %s %s%s -d %s\n", Name, opt, arg, fishQuote(sf.usage))
+	}
+}
+
+func printZshInit(out io.Writer, flags []shellFlag) {
+	shellTemplate(out, zshPreamble)
+
+	for _, sf := range flags {
+		spec := strings.ReplaceAll(sf.name, "+", `\+`) // A trailing "+" is special to _arguments
+		switch {
+		case sf.always:
+			spec += "=-"
+		case sf.arg != shellArgNone:
+			spec += "="
+		}
+		spec += "[" + zshEscape(sf.usage) + "]"
+		switch sf.arg {
+		case shellArgNone:
+			if sf.always {
+				spec += "::name: "
+			}
+		case shellArgAny:
+			spec += ":value: "
+		case shellArgFile:
+			spec += ":file:_files"
+		case shellArgAge:
+			spec += ":age:_" + Name + "_ages"
+		case shellArgTypes:
+			spec += ":type:_values -s , type " + strings.Join(shellTypes(), " ")
+		case shellArgUser:
+			spec += ":user:_users"
+		case shellArgGroup:
+			spec += ":group:_groups"
+		case shellArgShell:
+			spec += ":shell:(" + strings.Join(shellNames, " ") + ")"
+		}
+		fmt.Fprintf(out, "\t\t%s \\\n", shQuote(spec))
+	}
+
+	fmt.Fprintf(out, `		'*:path:_files -/'
+}
+(( $+functions[compdef] )) && compdef _%s %s fcd
+`, Name, Name)
+}
+
+// shQuote single quotes s for bash and zsh.
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote single quotes s for fish which, unlike other shells, supports backslash
+// escapes within single quotes.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// zshEscape escapes the characters which are special within an _arguments description.
+func zshEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintShellInit(t *testing.T) {
+	fs := flag.NewFlagSet(Name, flag.ContinueOnError)
+	cfg := newConfig(fs, func() (string, error) { return "", nil })
+	cfg.setFlags()
+
+	for _, shell := range shellNames {
+		var out bytes.Buffer
+		err := printShellInit(&out, shell, fs)
+		if err != nil {
+			t.Error(shell, err)
+			continue
+		}
+		script := out.String()
+		for _, want := range []string{"fcd", "fj", "-visit"} {
+			if !strings.Contains(script, want) {
+				t.Error(shell, "Missing", want)
+			}
+		}
+		fs.VisitAll(func(f *flag.Flag) {
+			escaped := strings.ReplaceAll(f.Name, "+", `\+`) // As zsh requires
+			if !strings.Contains(script, f.Name) && !strings.Contains(script, escaped) {
+				t.Error(shell, "Missing completion for", f.Name)
+			}
+		})
+		units := strings.Join(shellAgeUnits(), " ") // As completed after an -age number
+		if shell == "zsh" {
+			units = "{" + strings.Join(shellAgeUnits(), ",") + "}"
+		}
+		for _, want := range []string{strings.Join(shellTypes(), " "), units} {
+			if !strings.Contains(script, want) {
+				t.Error(shell, "Missing", want)
+			}
+		}
+
+		// Syntax check if the shell is installed
+		path, err := exec.LookPath(shell)
+		if err != nil {
+			continue
+		}
+		file := filepath.Join(t.TempDir(), "init")
+		os.WriteFile(file, out.Bytes(), 0600)
+		check := exec.Command(path, "-n", file)
+		if shell == "fish" {
+			check = exec.Command(path, "--no-execute", file)
+		}
+		if msg, err := check.CombinedOutput(); err != nil {
+			t.Error(shell, "Syntax error", err, string(msg))
+		}
+	}
+
+	if err := printShellInit(&bytes.Buffer{}, "csh", fs); err == nil {
+		t.Error("Expected error for unsupported shell")
+	}
+}
+
+func TestShellFlags(t *testing.T) {
+	fs := flag.NewFlagSet(Name, flag.ContinueOnError)
+	cfg := newConfig(fs, func() (string, error) { return "", nil })
+	cfg.setFlags()
+
+	want := map[string]shellFlag{
+		"0":              {name: "-0", arg: shellArgNone},
+		"age":            {name: "--age", arg: shellArgAge},
+		"count":          {name: "--count", arg: shellArgAny},
+		"itypes":         {name: "--itypes", arg: shellArgTypes},
+		"pdeleted":       {name: "--pdeleted", arg: shellArgNone},
+		"since-last-run": {name: "--since-last-run", arg: shellArgNone, always: true},
+	}
+	for _, sf := range shellFlags(fs) {
+		if w, ok := want[sf.bare]; ok {
+			if sf.name != w.name || sf.arg != w.arg || sf.always != w.always {
+				t.Error(sf.bare, "Got", sf, "want", w)
+			}
+		}
+	}
+
+	if units := strings.Join(shellAgeUnits(), ""); units != "smhDWMY" {
+		t.Error("Age units out of order", units)
+	}
+}
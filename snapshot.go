@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotVersion identifies the format of saved snapshots. Snapshots of any other
+// version are rejected rather than misinterpreted.
+const snapshotVersion = 1
+
+// snapshot is the persistent form of the per-directory activity of a scan. Every scanned
+// directory is present, regardless of whether it made the printed list, so that a later
+// scan can determine which directories appeared and disappeared as well as which
+// changed.
+type snapshot struct {
+	Version int                      `json:"version"`
+	Time    time.Time                `json:"time"` // Base time of the scan
+	Dirs    map[string]snapshotEntry `json:"dirs"`
+}
+
+// snapshotEntry is the youngest entry of a directory. A directory without qualifying
+// activity has an empty Path.
+type snapshotEntry struct {
+	Path    string    `json:"path,omitempty"`
+	Type    string    `json:"type,omitempty"` // As printed, thus fTypeDeleted is retained
+	ModTime time.Time `json:"mtime"`
+}
+
+// Diff statuses in the order they are printed
+const (
+	diffAppeared    = "appeared"
+	diffActive      = "active"
+	diffQuiet       = "quiet"
+	diffDisappeared = "disappeared"
+)
+
+var diffOrder = []string{diffAppeared, diffActive, diffQuiet, diffDisappeared}
+
+// newSnapshot creates a snapshot from the tracked activity of the scanner.
+func (scn *scanner) newSnapshot() *snapshot {
+	snap := &snapshot{Version: snapshotVersion, Time: scn.baseTime,
+		Dirs: make(map[string]snapshotEntry, len(scn.scanned))}
+	for dir := range scn.scanned {
+		var se snapshotEntry
+		if c, ok := scn.activity[dir]; ok {
+			se = snapshotEntry{Path: c.path, Type: c.fType(), ModTime: c.modTime}
+		}
+		snap.Dirs[dir] = se
+	}
+
+	return snap
+}
+
+// save writes the snapshot to path. The write is via a temporary file in the same
+// directory so that a failed save never destroys a previous snapshot.
+func (snap *snapshot) save(path string) error {
+	data, err := json.MarshalIndent(snap, "", " ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// loadSnapshot reads a snapshot previously written by save.
+func loadSnapshot(path string) (*snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snap := &snapshot{}
+	err = json.Unmarshal(data, snap)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("%s: unsupported snapshot version %d", path, snap.Version)
+	}
+	for dir, se := range snap.Dirs {
+		if _, ok := validFTypes[se.Type]; len(se.Path) > 0 && !ok && se.Type != fTypeUnknown {
+			return nil, fmt.Errorf("%s: invalid type '%s' for %s", path, se.Type, dir)
+		}
+	}
+
+	return snap, nil
+}
+
+// candidate converts a snapshot entry back into a candidate with an age relative to
+// baseTime. Returns nil if the entry has no activity.
+func (se snapshotEntry) candidate(baseTime time.Time) *candidate {
+	if len(se.Path) == 0 {
+		return nil
+	}
+	mode := validFTypes[se.Type]
+	if se.Type == fTypeUnknown { // Irregular entries, such as from a listing, are not in validFTypes
+		mode = fs.ModeIrregular
+	}
+	c := &candidate{deleted: se.Type == fTypeDeleted}
+	c.set(se.Path, mode, baseTime, se.ModTime)
+
+	return c
+}
+
+// diff compares the current scan with an earlier snapshot and classifies each directory
+// which differs:
+//
+//	appeared:    scanned now but not in the snapshot
+//	active:      has activity more recent than in the snapshot
+//	quiet:       was within -age when the snapshot was taken, but is no longer
+//	disappeared: in the snapshot but not scanned now
+//
+// Quiet is only meaningful if -age is set. The returned map contains candidates for each
+// status with ages relative to the current scan. A disappeared directory without any
+// recorded activity is represented by the directory itself.
+func (scn *scanner) diff(old *snapshot) map[string]*candidates {
+	current := scn.newSnapshot()
+	changes := make(map[string]*candidates)
+	add := func(status string, c *candidate) {
+		if changes[status] == nil {
+			changes[status] = newCandidates(0, age{})
+		}
+		changes[status].addMaybe(c)
+	}
+	dirOnly := func(dir string, baseTime time.Time) *candidate {
+		c := &candidate{}
+		c.set(dir, validFTypes[fTypeDir], baseTime, baseTime)
+		return c
+	}
+
+	for dir, se := range current.Dirs {
+		oldSe, ok := old.Dirs[dir]
+		c := se.candidate(scn.baseTime)
+		switch {
+		case !ok:
+			if c == nil {
+				c = dirOnly(dir, scn.baseTime)
+			}
+			add(diffAppeared, c)
+		case c != nil && se.ModTime.After(oldSe.ModTime):
+			add(diffActive, c)
+		case c != nil && scn.cfg.maxAge.seconds > 0 && len(oldSe.Path) > 0:
+			then := oldSe.candidate(old.Time)
+			if !then.age.gt(scn.cfg.maxAge, true) && c.age.gt(scn.cfg.maxAge, true) {
+				add(diffQuiet, c)
+			}
+		}
+	}
+
+	for dir, oldSe := range old.Dirs {
+		if _, ok := current.Dirs[dir]; ok {
+			continue
+		}
+		c := oldSe.candidate(scn.baseTime)
+		if c == nil {
+			c = dirOnly(dir, old.Time)
+			c.age.setFromTime(scn.baseTime, old.Time)
+		}
+		add(diffDisappeared, c)
+	}
+
+	for _, can := range changes {
+		sortCandidates(can)
+	}
+
+	return changes
+}
+
+// printDiff prints the changes returned by diff with each row prefixed by its status.
+func (scn *scanner) printDiff(out io.Writer, changes map[string]*candidates) {
+	width := 0
+	for _, can := range changes {
+		width = max(width, can.maxAgeWidth())
+	}
+	for _, status := range diffOrder {
+		if can, ok := changes[status]; ok {
+			scn.printPrefixed(out, fmt.Sprintf("%-11s ", status), can, width)
+		}
+	}
+}
+
+// printPrefixed prints the candidates in the normal format with each row prefixed.
+func (scn *scanner) printPrefixed(out io.Writer, prefix string, can *candidates, width int) {
+	var buf bytes.Buffer
+	scn.printList(&buf, can, width, (*age).compactString)
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if len(line) > 0 {
+			fmt.Fprint(out, prefix, line)
+		}
+	}
+}
+
+// sortCandidates sorts youngest first with identical ages in path order so that output
+// is deterministic.
+func sortCandidates(can *candidates) {
+	sort.Slice(can.cf, func(i, j int) bool {
+		if can.cf[i].age.seconds != can.cf[j].age.seconds {
+			return can.cf[i].age.seconds < can.cf[j].age.seconds
+		}
+		return can.cf[i].path < can.cf[j].path
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	dir := t.TempDir()
+	snap := filepath.Join(t.TempDir(), "snap.json")
+	now := time.Now()
+	files := []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"a/old", -5 * time.Hour},
+		{"b/file", -1 * time.Hour},
+		{"c/file", -5 * time.Hour},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f.name))
+		os.MkdirAll(filepath.Dir(path), 0700)
+		os.WriteFile(path, nil, 0600)
+		os.Chtimes(path, now.Add(f.offset), now.Add(f.offset))
+	}
+	noConfig := func() (string, error) { return "", nil }
+
+	var stdout, stderr strings.Builder
+	ec := realMain(now, []string{"-save", snap, "-age", "2h", dir}, noConfig, noEnv, nil, &stdout, &stderr)
+	if ec != EX_OK {
+		t.Fatal("Save failed", ec, stderr.String())
+	}
+	if stdout.String() != "1h:f:"+filepath.Join(dir, "b", "file")+"\n" {
+		t.Error("-save should not change normal output", stdout.String())
+	}
+
+	// a becomes active, b goes quiet with the passage of time, c disappears and d
+	// appears.
+	later := now.Add(2 * time.Hour)
+	path := filepath.Join(dir, "a", "new")
+	os.WriteFile(path, nil, 0600)
+	os.Chtimes(path, now.Add(-10*time.Minute), now.Add(-10*time.Minute))
+	os.RemoveAll(filepath.Join(dir, "c"))
+	path = filepath.Join(dir, "d", "file")
+	os.MkdirAll(filepath.Dir(path), 0700)
+	os.WriteFile(path, nil, 0600)
+	os.Chtimes(path, now.Add(-30*time.Minute), now.Add(-30*time.Minute))
+
+	stdout.Reset()
+	stderr.Reset()
+	ec = realMain(later, []string{"-diff", snap, "-save", snap, "-age", "2h", dir}, noConfig, noEnv, nil,
+		&stdout, &stderr)
+	if ec != EX_OK {
+		t.Fatal("Diff failed", ec, stderr.String())
+	}
+	exp := "appeared    3h:f:" + filepath.Join(dir, "d", "file") + "\n" +
+		"active      2h:f:" + filepath.Join(dir, "a", "new") + "\n" +
+		"quiet       3h:f:" + filepath.Join(dir, "b", "file") + "\n" +
+		"disappeared 7h:f:" + filepath.Join(dir, "c", "file") + "\n"
+	if stdout.String() != exp {
+		t.Errorf("Expected\n%sGot\n%s%s", exp, stdout.String(), stderr.String())
+	}
+
+	// The snapshot was replaced so an immediate diff shows no changes
+	stdout.Reset()
+	ec = realMain(later, []string{"-diff", snap, "-age", "2h", dir}, noConfig, noEnv, nil, &stdout, &stderr)
+	if ec != EX_OK || stdout.Len() > 0 {
+		t.Error("Expected no differences, got", ec, stdout.String(), stderr.String())
+	}
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		content string
+		err     string
+	}{
+		{"not json", "invalid character"},
+		{`{"version": 99}`, "unsupported snapshot version 99"},
+		{`{"version": 1, "dirs": {"/a": {"path": "/a/b", "type": "Q"}}}`, "invalid type 'Q' for /a"},
+	}
+
+	for ix, tc := range testCases {
+		path := filepath.Join(dir, "snap")
+		os.WriteFile(path, []byte(tc.content), 0600)
+		_, err := loadSnapshot(path)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Error(ix, "Expected error", tc.err, "got", err)
+		}
+	}
+
+	noConfig := func() (string, error) { return "", nil }
+	var stdout, stderr strings.Builder
+	ec := realMain(time.Now(), []string{"-diff", filepath.Join(dir, "noexist"), "testdata/maxdir"},
+		noConfig, noEnv, nil, &stdout, &stderr)
+	if ec != EX_NOINPUT || !strings.Contains(stderr.String(), "no such file") {
+		t.Error("Expected EX_NOINPUT, got", ec, stderr.String())
+	}
+
+	stderr.Reset()
+	ec = realMain(time.Now(), []string{"-save", filepath.Join(dir, "noexist", "snap"), "testdata/maxdir"},
+		noConfig, noEnv, nil, &stdout, &stderr)
+	if ec != EX_IOERR || !strings.Contains(stderr.String(), "Error: Saving Snapshot") {
+		t.Error("Expected EX_IOERR, got", ec, stderr.String())
+	}
+}
+
+// A snapshot must always be readable by the program that wrote it, including irregular
+// entries which are printed, and saved, as fTypeUnknown.
+func TestSnapshotIrregular(t *testing.T) {
+	dir := t.TempDir()
+	listing := filepath.Join(dir, "list.txt")
+	snap := filepath.Join(dir, "snap.json")
+	os.WriteFile(listing, []byte("1700000000.0 d /r\n1700000001.0 d /r/a\n1700000002.0 U /r/a/door\n"), 0600)
+	noConfig := func() (string, error) { return "", nil }
+
+	now := time.Unix(1700000100, 0)
+	var stdout, stderr strings.Builder
+	ec := realMain(now, []string{"-listing", listing, "-save", snap}, noConfig, noEnv, nil, &stdout, &stderr)
+	if ec != EX_OK || !strings.Contains(stdout.String(), ":?:"+filepath.FromSlash("/r/a/door")+"\n") {
+		t.Fatal("Save failed", ec, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	ec = realMain(now, []string{"-listing", listing, "-diff", snap}, noConfig, noEnv, nil, &stdout, &stderr)
+	if ec != EX_OK || stdout.Len() > 0 {
+		t.Error("Expected no changes", ec, stdout.String(), stderr.String())
+	}
+
+	prev, err := loadSnapshot(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := prev.Dirs[filepath.FromSlash("/r/a")].candidate(now)
+	if c == nil || c.fType() != fTypeUnknown || c.mode&fs.ModeIrregular == 0 {
+		t.Error("Irregular entry did not round-trip", c)
+	}
+}
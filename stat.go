@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io/fs"
+)
+
+// statDetails contains the system-dependent file details which are not exposed by
+// fs.FileInfo. Not all systems (or file systems) provide these details so each group of
+// values is accompanied by a flag indicating whether they are meaningful or not.
+type statDetails struct {
+	hasOwner bool
+	uid      uint32
+	gid      uint32
+
+	hasID bool
+	id    fileID
+}
+
+// fileID uniquely identifies a file system object on a running system regardless of the
+// path used to reach it.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// getStatDetails returns the statDetails of fi. Virtual file systems, such as memFS,
+// supply statDetails directly via Sys(), otherwise the details are extracted from the
+// system-dependent Sys() value.
+func getStatDetails(fi fs.FileInfo) statDetails {
+	if sd, ok := fi.Sys().(statDetails); ok {
+		return sd
+	}
+
+	return getSysStatDetails(fi)
+}
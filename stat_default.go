@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"io/fs"
+)
+
+// getSysStatDetails returns an empty statDetails as no Unix stat details are available on
+// this system.
+func getSysStatDetails(fi fs.FileInfo) (sd statDetails) {
+	return
+}
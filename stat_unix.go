@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// getSysStatDetails extracts the Unix stat details from the underlying Sys() value, if
+// available.
+func getSysStatDetails(fi fs.FileInfo) (sd statDetails) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || st == nil {
+		return
+	}
+	sd.hasOwner = true
+	sd.uid = uint32(st.Uid)
+	sd.gid = uint32(st.Gid)
+	sd.hasID = true
+	sd.id.dev = uint64(st.Dev) // Types vary by system, thus the casts
+	sd.id.ino = uint64(st.Ino)
+
+	return
+}
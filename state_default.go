@@ -0,0 +1,13 @@
+//go:build !unix || darwin
+
+package main
+
+import (
+	"os"
+)
+
+// userStateDir returns the base directory for persistent state. Systems without an XDG
+// convention keep state alongside configuration.
+func userStateDir() (string, error) {
+	return os.UserConfigDir()
+}
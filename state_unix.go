@@ -0,0 +1,24 @@
+//go:build unix && !darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// userStateDir returns the base directory for persistent state as defined by the XDG Base
+// Directory Specification. $XDG_STATE_HOME is used if it is an absolute path, otherwise
+// $HOME/.local/state.
+func userStateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home := os.Getenv("HOME")
+	if len(home) == 0 {
+		return "", errors.New("neither $XDG_STATE_HOME nor $HOME are defined")
+	}
+
+	return filepath.Join(home, ".local", "state"), nil
+}
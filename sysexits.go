@@ -6,9 +6,11 @@ package main
 // core go package. Thus this.
 
 const (
-	EX_OK     int = 0
-	EX_USAGE      = 64
-	EX_OSFILE     = 72
-	EX_IOERR      = 74
-	EX_CONFIG     = 78
+	EX_OK          int = 0
+	EX_USAGE           = 64
+	EX_NOINPUT         = 66
+	EX_UNAVAILABLE     = 69
+	EX_OSFILE          = 72
+	EX_IOERR           = 74
+	EX_CONFIG          = 78
 )
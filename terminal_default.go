@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// terminal is not supported on this platform, so -tui is unavailable.
+type terminal struct {
+	f       *os.File
+	resized chan os.Signal
+}
+
+func openTerminal() (*terminal, error) {
+	return nil, errors.New("-tui is not supported on this platform")
+}
+
+func (t *terminal) close() {}
+
+func (t *terminal) size() (width, height int) { return 80, 24 }
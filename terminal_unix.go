@@ -0,0 +1,91 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// terminal is the controlling terminal in raw mode. It is opened directly rather than
+// using stdin and stdout so that the browser works while stdout is being captured, as in
+// cd "$(fad -tui)".
+type terminal struct {
+	f       *os.File
+	saved   syscall.Termios
+	resized chan os.Signal
+}
+
+// winsize mirrors struct winsize from <sys/ioctl.h>.
+type winsize struct {
+	row, col, xpixel, ypixel uint16
+}
+
+func openTerminal() (*terminal, error) {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("-tui requires a terminal: %w", err)
+	}
+	t := &terminal{f: f, resized: make(chan os.Signal, 1)}
+	if err = t.ioctl(ioctlGetTermios, unsafe.Pointer(&t.saved)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("-tui requires a terminal: %w", err)
+	}
+
+	raw := t.saved // As per cfmakeraw(3)
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err = t.ioctl(ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("-tui: %w", err)
+	}
+	signal.Notify(t.resized, syscall.SIGWINCH)
+
+	return t, nil
+}
+
+// close restores the terminal to its original mode.
+func (t *terminal) close() {
+	signal.Stop(t.resized)
+	t.ioctl(ioctlSetTermios, unsafe.Pointer(&t.saved))
+	t.f.Close()
+}
+
+// size returns the width and height of the terminal with a fallback of 80x24.
+func (t *terminal) size() (width, height int) {
+	var ws winsize
+	if t.ioctl(syscall.TIOCGWINSZ, unsafe.Pointer(&ws)) != nil || ws.col == 0 || ws.row == 0 {
+		return 80, 24
+	}
+
+	return int(ws.col), int(ws.row)
+}
+
+// ioctl is via SyscallConn() as Fd() would put the terminal into blocking mode.
+func (t *terminal) ioctl(req uintptr, arg unsafe.Pointer) error {
+	rc, err := t.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The -tui browser is split between a terminal-independent model, which holds all state
+// and renders to an io.Writer, and browse(), which connects the model to the controlling
+// terminal. This keeps nearly all of the browser testable without a terminal.
+
+// tuiKey identifies a decoded keystroke. Printable characters are tuiRune.
+type tuiKey int
+
+const (
+	tuiRune tuiKey = iota
+	tuiUp
+	tuiDown
+	tuiLeft
+	tuiRight
+	tuiPgUp
+	tuiPgDn
+	tuiHome
+	tuiEnd
+	tuiEnter
+	tuiEsc
+	tuiBackspace
+	tuiCtrlC
+	tuiIgnored
+)
+
+type tuiInput struct {
+	key tuiKey
+	r   rune // Only set for tuiRune
+}
+
+// tuiSequences maps the escape sequences of common terminals to keys. Both the CSI and
+// SS3 forms of the cursor keys are recognized.
+var tuiSequences = map[string]tuiKey{
+	"\033[A": tuiUp, "\033OA": tuiUp,
+	"\033[B": tuiDown, "\033OB": tuiDown,
+	"\033[C": tuiRight, "\033OC": tuiRight,
+	"\033[D": tuiLeft, "\033OD": tuiLeft,
+	"\033[H": tuiHome, "\033OH": tuiHome, "\033[1~": tuiHome, "\033[7~": tuiHome,
+	"\033[F": tuiEnd, "\033OF": tuiEnd, "\033[4~": tuiEnd, "\033[8~": tuiEnd,
+	"\033[5~": tuiPgUp,
+	"\033[6~": tuiPgDn,
+}
+
+// parseTuiInput decodes the bytes from a single terminal read into keystrokes. A lone
+// ESC is the Escape key, while an unrecognized escape sequence is ignored in its
+// entirety rather than being mistaken for typed characters.
+func parseTuiInput(b []byte) (inputs []tuiInput) {
+	for len(b) > 0 {
+		if b[0] == '\033' && len(b) > 1 {
+			n := escapeLength(b)
+			if k, ok := tuiSequences[string(b[:n])]; ok {
+				inputs = append(inputs, tuiInput{key: k})
+			} else {
+				inputs = append(inputs, tuiInput{key: tuiIgnored})
+			}
+			b = b[n:]
+			continue
+		}
+		switch b[0] {
+		case '\033':
+			inputs = append(inputs, tuiInput{key: tuiEsc})
+		case '\r', '\n':
+			inputs = append(inputs, tuiInput{key: tuiEnter})
+		case 0x7f, '\b':
+			inputs = append(inputs, tuiInput{key: tuiBackspace})
+		case 0x03:
+			inputs = append(inputs, tuiInput{key: tuiCtrlC})
+		case 0x0e: // Ctrl-N
+			inputs = append(inputs, tuiInput{key: tuiDown})
+		case 0x10: // Ctrl-P
+			inputs = append(inputs, tuiInput{key: tuiUp})
+		default:
+			r, n := utf8.DecodeRune(b)
+			if r < ' ' || r == utf8.RuneError {
+				inputs = append(inputs, tuiInput{key: tuiIgnored})
+			} else {
+				inputs = append(inputs, tuiInput{key: tuiRune, r: r})
+			}
+			b = b[n:]
+			continue
+		}
+		b = b[1:]
+	}
+
+	return
+}
+
+// escapeLength returns the length of the escape sequence at the start of b. CSI
+// sequences end with a byte in the range 0x40-0x7e and SS3 sequences are three bytes.
+// An ESC followed by anything else is Alt+key which is treated as two bytes.
+func escapeLength(b []byte) int {
+	switch b[1] {
+	case '[':
+		for ix := 2; ix < len(b); ix++ {
+			if b[ix] >= 0x40 && b[ix] <= 0x7e {
+				return ix + 1
+			}
+		}
+		return len(b)
+	case 'O':
+		return min(3, len(b))
+	}
+
+	return 2
+}
+
+// tuiAction tells browse() what to do after the model has handled a keystroke.
+type tuiAction int
+
+const (
+	tuiContinue tuiAction = iota
+	tuiQuit
+	tuiSelect
+	tuiRescan
+)
+
+// tuiRow is a candidate as printed in the normal list along with the directory which is
+// selected by choosing it.
+type tuiRow struct {
+	text     string
+	dir      string
+	archived bool // Within an archive so dir cannot be selected or opened
+}
+
+// tuiEntry is a directory entry shown when drilling down into a directory.
+type tuiEntry struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// tuiList is a scrollable list with a cursor.
+type tuiList struct {
+	cursor int
+	top    int // First visible row
+}
+
+// move moves the cursor by delta within a list of length n and scrolls so that the
+// cursor remains visible within height rows.
+func (tl *tuiList) move(delta, n, height int) {
+	tl.cursor = max(0, min(tl.cursor+delta, n-1))
+	if tl.cursor < tl.top {
+		tl.top = tl.cursor
+	}
+	if height > 0 && tl.cursor >= tl.top+height {
+		tl.top = tl.cursor - height + 1
+	}
+	tl.top = max(0, min(tl.top, n-height))
+}
+
+// tuiModel holds all of the browser state.
+type tuiModel struct {
+	all     []tuiRow // All candidates in ranked order
+	rows    []tuiRow // Candidates matching the filter
+	list    tuiList
+	filter  string
+	editing bool // Keystrokes are added to the filter
+	status  string
+
+	listDir func(dir string) ([]tuiEntry, error)
+	drill   string // Directory being shown, if any
+	entries []tuiEntry
+	dlist   tuiList
+
+	height   int // Rows available for the list
+	selected string
+}
+
+func newTuiModel(scn *scanner) *tuiModel {
+	tm := &tuiModel{listDir: readTuiDir}
+	tm.load(scn)
+
+	return tm
+}
+
+// load replaces the candidates with those of scn while retaining the filter and, if
+// possible, the cursor position.
+func (tm *tuiModel) load(scn *scanner) {
+	var current string
+	if tm.list.cursor < len(tm.rows) {
+		current = tm.rows[tm.list.cursor].dir
+	}
+
+	var buf bytes.Buffer
+	scn.printList(&buf, scn.allCandidates, scn.allCandidates.maxAgeWidth(), (*age).compactString)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	tm.all = tm.all[:0]
+	for ix, c := range scn.allCandidates.cf {
+		row := tuiRow{text: lines[ix], dir: filepath.Clean(c.path),
+			archived: strings.Contains(c.path, archiveSeparator+string(filepath.Separator))}
+		if !c.deleted && !c.isDir() {
+			row.dir = filepath.Dir(row.dir)
+		}
+		tm.all = append(tm.all, row)
+	}
+
+	tm.status = fmt.Sprintf("%d directories scanned, %d errors", scn.dirCount, scn.errorCount)
+	tm.applyFilter()
+	for ix, row := range tm.rows {
+		if row.dir == current {
+			tm.list.cursor = ix
+			tm.list.move(0, len(tm.rows), tm.height)
+			break
+		}
+	}
+}
+
+// applyFilter selects the rows containing the filter ignoring case and resets the cursor
+// to the top.
+func (tm *tuiModel) applyFilter() {
+	tm.rows = tm.rows[:0]
+	lower := strings.ToLower(tm.filter)
+	for _, row := range tm.all {
+		if strings.Contains(strings.ToLower(row.text), lower) {
+			tm.rows = append(tm.rows, row)
+		}
+	}
+	tm.list = tuiList{}
+}
+
+// handle applies the keystroke to the model and returns the resulting action.
+func (tm *tuiModel) handle(in tuiInput) tuiAction {
+	if in.key == tuiCtrlC {
+		return tuiQuit
+	}
+	if len(tm.drill) > 0 {
+		return tm.handleDrill(in)
+	}
+
+	if tm.editing {
+		switch in.key {
+		case tuiRune:
+			tm.filter += string(in.r)
+			tm.applyFilter()
+			return tuiContinue
+		case tuiBackspace:
+			if len(tm.filter) > 0 {
+				_, n := utf8.DecodeLastRuneInString(tm.filter)
+				tm.filter = tm.filter[:len(tm.filter)-n]
+				tm.applyFilter()
+			}
+			return tuiContinue
+		case tuiEsc:
+			tm.editing = false
+			tm.filter = ""
+			tm.applyFilter()
+			return tuiContinue
+		case tuiEnter:
+			tm.editing = false
+			return tuiContinue
+		}
+	}
+
+	switch in.key {
+	case tuiRune:
+		switch in.r {
+		case 'q':
+			return tuiQuit
+		case 'j':
+			tm.list.move(1, len(tm.rows), tm.height)
+		case 'k':
+			tm.list.move(-1, len(tm.rows), tm.height)
+		case 'g':
+			tm.list.move(-len(tm.rows), len(tm.rows), tm.height)
+		case 'G':
+			tm.list.move(len(tm.rows), len(tm.rows), tm.height)
+		case 'l':
+			tm.open()
+		case '/':
+			tm.editing = true
+		case 'r':
+			return tuiRescan
+		}
+	case tuiUp:
+		tm.list.move(-1, len(tm.rows), tm.height)
+	case tuiDown:
+		tm.list.move(1, len(tm.rows), tm.height)
+	case tuiPgUp:
+		tm.list.move(-max(1, tm.height-1), len(tm.rows), tm.height)
+	case tuiPgDn:
+		tm.list.move(max(1, tm.height-1), len(tm.rows), tm.height)
+	case tuiHome:
+		tm.list.move(-len(tm.rows), len(tm.rows), tm.height)
+	case tuiEnd:
+		tm.list.move(len(tm.rows), len(tm.rows), tm.height)
+	case tuiRight:
+		tm.open()
+	case tuiEsc:
+		if len(tm.filter) > 0 {
+			tm.filter = ""
+			tm.applyFilter()
+		} else {
+			return tuiQuit
+		}
+	case tuiEnter:
+		if len(tm.rows) > 0 && !tm.refuseArchived() {
+			tm.selected = tm.rows[tm.list.cursor].dir
+			return tuiSelect
+		}
+	}
+
+	return tuiContinue
+}
+
+// handleDrill applies the keystroke while showing the entries of a directory.
+func (tm *tuiModel) handleDrill(in tuiInput) tuiAction {
+	n := len(tm.entries)
+	switch in.key {
+	case tuiRune:
+		switch in.r {
+		case 'q':
+			return tuiQuit
+		case 'j':
+			tm.dlist.move(1, n, tm.height)
+		case 'k':
+			tm.dlist.move(-1, n, tm.height)
+		case 'g':
+			tm.dlist.move(-n, n, tm.height)
+		case 'G':
+			tm.dlist.move(n, n, tm.height)
+		case 'h':
+			tm.drill = ""
+		case 'l':
+			tm.openEntry()
+		case 'r':
+			tm.openDir(tm.drill)
+		}
+	case tuiUp:
+		tm.dlist.move(-1, n, tm.height)
+	case tuiDown:
+		tm.dlist.move(1, n, tm.height)
+	case tuiPgUp:
+		tm.dlist.move(-max(1, tm.height-1), n, tm.height)
+	case tuiPgDn:
+		tm.dlist.move(max(1, tm.height-1), n, tm.height)
+	case tuiHome:
+		tm.dlist.move(-n, n, tm.height)
+	case tuiEnd:
+		tm.dlist.move(n, n, tm.height)
+	case tuiRight:
+		tm.openEntry()
+	case tuiLeft, tuiEsc, tuiBackspace:
+		tm.drill = ""
+	case tuiEnter:
+		tm.selected = tm.drill
+		if n > 0 && tm.entries[tm.dlist.cursor].mode.IsDir() {
+			tm.selected = filepath.Join(tm.drill, tm.entries[tm.dlist.cursor].name)
+		}
+		return tuiSelect
+	}
+
+	return tuiContinue
+}
+
+// open drills down into the directory of the row under the cursor.
+func (tm *tuiModel) open() {
+	if len(tm.rows) > 0 && !tm.refuseArchived() {
+		tm.openDir(tm.rows[tm.list.cursor].dir)
+	}
+}
+
+// refuseArchived reports in the status line, and returns true, if the row under the
+// cursor is within an archive as its directory does not exist in the file system.
+func (tm *tuiModel) refuseArchived() bool {
+	if !tm.rows[tm.list.cursor].archived {
+		return false
+	}
+	tm.status = "Error: " + tm.rows[tm.list.cursor].dir + " is within an archive"
+
+	return true
+}
+
+// openEntry drills further down if the entry under the cursor is a directory.
+func (tm *tuiModel) openEntry() {
+	if len(tm.entries) > 0 && tm.entries[tm.dlist.cursor].mode.IsDir() {
+		tm.openDir(filepath.Join(tm.drill, tm.entries[tm.dlist.cursor].name))
+	}
+}
+
+func (tm *tuiModel) openDir(dir string) {
+	entries, err := tm.listDir(dir)
+	if err != nil {
+		tm.status = "Error: " + err.Error()
+		return
+	}
+	tm.drill = dir
+	tm.entries = entries
+	tm.dlist = tuiList{}
+}
+
+// readTuiDir returns the entries of dir youngest first. Entries which vanish before they
+// can be examined are skipped.
+func readTuiDir(dir string) ([]tuiEntry, error) {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]tuiEntry, 0, len(des))
+	for _, de := range des {
+		fi, err := de.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, tuiEntry{name: de.Name(), mode: fi.Mode(), modTime: fi.ModTime()})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].modTime.After(entries[j].modTime)
+	})
+
+	return entries, nil
+}
+
+// render draws the whole screen. The first line is a title, the last line is for the
+// filter or help text and the remainder shows the list. Lines are truncated to width and
+// separated by "\r\n" as the terminal is in raw mode.
+func (tm *tuiModel) render(out io.Writer, width, height int, now time.Time) {
+	tm.height = max(1, height-2)
+	var lines []string
+	var cursor, top int
+	if len(tm.drill) > 0 {
+		lines = tm.entryLines(now)
+		tm.dlist.move(0, len(lines), tm.height)
+		cursor, top = tm.dlist.cursor, tm.dlist.top
+		lines = append([]string{Name + ": " + tm.drill}, lines...)
+	} else {
+		for _, row := range tm.rows {
+			lines = append(lines, row.text)
+		}
+		tm.list.move(0, len(lines), tm.height)
+		cursor, top = tm.list.cursor, tm.list.top
+		lines = append([]string{fmt.Sprintf("%s: %d of %d  %s", Name, len(tm.rows), len(tm.all), tm.status)},
+			lines...)
+	}
+
+	fmt.Fprint(out, "\033[H\033[2J") // Home and clear screen
+	fmt.Fprint(out, "\033[1m", truncate(lines[0], width), "\033[0m\r\n")
+	for ix := top; ix < top+tm.height; ix++ {
+		if ix+1 < len(lines) {
+			line := truncate(lines[ix+1], width)
+			if ix == cursor {
+				line = "\033[7m" + line + "\033[0m" // Reverse video
+			}
+			fmt.Fprint(out, line)
+		}
+		fmt.Fprint(out, "\r\n")
+	}
+
+	switch {
+	case tm.editing:
+		fmt.Fprint(out, truncate("/"+tm.filter, width))
+	case len(tm.drill) > 0:
+		fmt.Fprint(out, truncate("Enter:cd  Right:open  Left:back  r:reload  q:quit", width))
+	case len(tm.filter) > 0:
+		fmt.Fprint(out, truncate("Filter: "+tm.filter+"  Esc:clear  /:edit  Enter:cd  q:quit", width))
+	default:
+		fmt.Fprint(out, truncate("Enter:cd  Right:open  /:filter  r:rescan  q:quit", width))
+	}
+}
+
+// entryLines formats the drill-down entries with ages relative to now in the same form
+// as the main list.
+func (tm *tuiModel) entryLines(now time.Time) []string {
+	var ages []string
+	var width int
+	for _, e := range tm.entries {
+		var a age
+		a.setFromTime(now, e.modTime)
+		ages = append(ages, a.compactString())
+		width = max(width, len(ages[len(ages)-1]))
+	}
+	lines := make([]string, 0, len(tm.entries))
+	for ix, e := range tm.entries {
+		c := candidate{mode: e.mode}
+		lines = append(lines, fmt.Sprintf("%*s:%s:%s", width, ages[ix], c.fType(), e.name))
+	}
+
+	return lines
+}
+
+// truncate shortens s to at most width runes.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+
+	return string(r[:max(0, width)])
+}
+
+// browse runs the interactive browser on the controlling terminal until the user quits
+// or selects a directory, which is returned. rescan is called to replace the results on
+// request. Errors it reports are counted in the status line rather than disturbing the
+// display.
+func browse(scn *scanner, rescan func(stderr io.Writer) *scanner) (string, error) {
+	tty, err := openTerminal()
+	if err != nil {
+		return "", err
+	}
+	defer tty.close()
+
+	tm := newTuiModel(scn)
+	fmt.Fprint(tty.f, "\033[?1049h\033[?25l") // Alternate screen and hide cursor
+	defer fmt.Fprint(tty.f, "\033[?25h\033[?1049l")
+
+	keys := make(chan []byte)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			buf := make([]byte, 256)
+			n, err := tty.f.Read(buf)
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case keys <- buf[:n]:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Stop the reader before the terminal is restored so that it cannot consume input
+	// intended for the shell. An expired deadline interrupts a pending Read. If the
+	// terminal does not support deadlines the reader ends when the terminal is closed.
+	defer func() {
+		close(done)
+		if tty.f.SetReadDeadline(time.Now()) == nil {
+			<-stopped
+		}
+	}()
+
+	for {
+		width, height := tty.size()
+		var screen bytes.Buffer
+		tm.render(&screen, width, height, time.Now())
+		tty.f.Write(screen.Bytes())
+
+		select {
+		case err := <-errs:
+			return "", err
+		case <-tty.resized:
+		case b := <-keys:
+			for _, in := range parseTuiInput(b) {
+				switch tm.handle(in) {
+				case tuiQuit:
+					return "", nil
+				case tuiSelect:
+					return tm.selected, nil
+				case tuiRescan:
+					tm.status = "Scanning..."
+					screen.Reset()
+					tm.render(&screen, width, height, time.Now())
+					tty.f.Write(screen.Bytes())
+					tm.load(rescan(io.Discard))
+				}
+			}
+		}
+	}
+}
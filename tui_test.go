@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTuiInput(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want []tuiInput
+	}{
+		{"", nil},
+		{"ab", []tuiInput{{tuiRune, 'a'}, {tuiRune, 'b'}}},
+		{"\033", []tuiInput{{key: tuiEsc}}},
+		{"\033[A\033OB", []tuiInput{{key: tuiUp}, {key: tuiDown}}},
+		{"\033[5~\033[6~", []tuiInput{{key: tuiPgUp}, {key: tuiPgDn}}},
+		{"\033[1;5Cx", []tuiInput{{key: tuiIgnored}, {tuiRune, 'x'}}}, // Ctrl-Right
+		{"\r\x7f\x03", []tuiInput{{key: tuiEnter}, {key: tuiBackspace}, {key: tuiCtrlC}}},
+		{"é", []tuiInput{{tuiRune, 'é'}}},
+		{"\x01", []tuiInput{{key: tuiIgnored}}},
+	}
+
+	for ix, tc := range testCases {
+		got := parseTuiInput([]byte(tc.in))
+		if len(got) != len(tc.want) {
+			t.Error(ix, "Length mismatch", got, tc.want)
+			continue
+		}
+		for jx := range got {
+			if got[jx] != tc.want[jx] {
+				t.Error(ix, jx, "Got", got[jx], "want", tc.want[jx])
+			}
+		}
+	}
+}
+
+func TestTuiList(t *testing.T) {
+	testCases := []struct {
+		start     tuiList
+		delta     int
+		n, height int
+		want      tuiList
+	}{
+		{tuiList{}, 1, 10, 5, tuiList{1, 0}},
+		{tuiList{}, -1, 10, 5, tuiList{0, 0}},
+		{tuiList{4, 0}, 1, 10, 5, tuiList{5, 1}},
+		{tuiList{0, 0}, 100, 10, 5, tuiList{9, 5}},
+		{tuiList{9, 5}, -7, 10, 5, tuiList{2, 2}},
+		{tuiList{}, 1, 0, 5, tuiList{0, 0}},     // Empty list
+		{tuiList{8, 5}, 0, 3, 5, tuiList{2, 0}}, // List shrunk
+	}
+
+	for ix, tc := range testCases {
+		got := tc.start
+		got.move(tc.delta, tc.n, tc.height)
+		if got != tc.want {
+			t.Error(ix, "Got", got, "want", tc.want)
+		}
+	}
+}
+
+// testTuiModel returns a model of three candidates with drill-down served from a fixed
+// set of entries.
+func testTuiModel(t *testing.T) *tuiModel {
+	var stderr bytes.Buffer
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+		func() (string, error) { return "", nil })
+	scn, _, err := testScannerSetup(cfg, &stderr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ix, p := range []string{"src/web/main.go", "docs/README", "src/lib"} {
+		c := &candidate{}
+		mode := fs.FileMode(0)
+		if p == "src/lib" {
+			mode = fs.ModeDir
+		}
+		c.set(p, mode, scn.baseTime, scn.baseTime.Add(-time.Duration(ix+1)*time.Hour))
+		scn.allCandidates.addMaybe(c)
+	}
+	scn.allCandidates.sortAscending()
+
+	tm := newTuiModel(scn)
+	tm.height = 10
+	tm.listDir = func(dir string) ([]tuiEntry, error) {
+		if dir == filepath.Join("src", "web") {
+			return []tuiEntry{{"main.go", 0, scn.baseTime}, {"static", fs.ModeDir, scn.baseTime}}, nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	return tm
+}
+
+func TestTuiModel(t *testing.T) {
+	tm := testTuiModel(t)
+	keys := func(s string) {
+		for _, in := range parseTuiInput([]byte(s)) {
+			tm.handle(in)
+		}
+	}
+
+	if len(tm.rows) != 3 || tm.rows[0].dir != filepath.Join("src", "web") {
+		t.Fatal("Unexpected rows", tm.rows)
+	}
+	if tm.rows[2].dir != filepath.Join("src", "lib") {
+		t.Error("Directory candidate should select itself", tm.rows[2])
+	}
+
+	// Navigation
+	keys("jj")
+	if tm.list.cursor != 2 {
+		t.Error("Cursor should be at 2", tm.list.cursor)
+	}
+	keys("\033[H")
+	if tm.list.cursor != 0 {
+		t.Error("Home should return to 0", tm.list.cursor)
+	}
+
+	// Incremental filtering is case insensitive and Esc clears it
+	keys("/SRC")
+	if !tm.editing || len(tm.rows) != 2 {
+		t.Error("Filter should match two rows", tm.editing, tm.rows)
+	}
+	keys("\x7f\x7f\x7f\x7fdocs\r")
+	if tm.editing || len(tm.rows) != 1 || tm.rows[0].dir != "docs" {
+		t.Error("Filter should match docs", tm.editing, tm.rows)
+	}
+	keys("\033")
+	if len(tm.filter) != 0 || len(tm.rows) != 3 {
+		t.Error("Esc should clear the filter", tm.filter, tm.rows)
+	}
+
+	// Drill-down and back
+	keys("\033[C")
+	if tm.drill != filepath.Join("src", "web") || len(tm.entries) != 2 {
+		t.Fatal("Should have drilled down", tm.drill, tm.entries)
+	}
+	keys("\033[D")
+	if len(tm.drill) != 0 {
+		t.Error("Left should leave drill-down", tm.drill)
+	}
+
+	// Drill-down failure is reported in the status line
+	keys("j\033[C")
+	if len(tm.drill) != 0 || !strings.Contains(tm.status, "Error:") {
+		t.Error("Failed drill-down should report error", tm.drill, tm.status)
+	}
+
+	// Selecting a sub-directory entry when drilled down
+	keys("k\033[Cj")
+	if act := tm.handle(tuiInput{key: tuiEnter}); act != tuiSelect {
+		t.Error("Enter should select", act)
+	}
+	if tm.selected != filepath.Join("src", "web", "static") {
+		t.Error("Wrong selection", tm.selected)
+	}
+
+	if act := tm.handle(tuiInput{key: tuiRune, r: 'r'}); act != tuiContinue {
+		t.Error("r in drill-down should only reload", act)
+	}
+	keys("h")
+	if act := tm.handle(tuiInput{key: tuiRune, r: 'r'}); act != tuiRescan {
+		t.Error("r should rescan", act)
+	}
+	if act := tm.handle(tuiInput{key: tuiRune, r: 'q'}); act != tuiQuit {
+		t.Error("q should quit", act)
+	}
+}
+
+func TestTuiArchived(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+		func() (string, error) { return "", nil })
+	scn, _, err := testScannerSetup(cfg, &stderr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &candidate{}
+	c.set(filepath.Join("backup.tar"+archiveSeparator, "etc", "hosts"), 0, scn.baseTime, scn.baseTime)
+	scn.allCandidates.addMaybe(c)
+
+	tm := newTuiModel(scn)
+	if len(tm.rows) != 1 || !tm.rows[0].archived {
+		t.Fatal("Row should be archived", tm.rows)
+	}
+	if act := tm.handle(tuiInput{key: tuiEnter}); act != tuiContinue || len(tm.selected) > 0 {
+		t.Error("Archived row should not be selected", act, tm.selected)
+	}
+	if !strings.Contains(tm.status, "within an archive") {
+		t.Error("Refusal should be reported", tm.status)
+	}
+	tm.handle(tuiInput{key: tuiRight})
+	if len(tm.drill) > 0 {
+		t.Error("Archived row should not be opened", tm.drill)
+	}
+}
+
+func TestTuiRender(t *testing.T) {
+	tm := testTuiModel(t)
+	var out bytes.Buffer
+	tm.render(&out, 20, 4, time.Now())
+	lines := strings.Split(out.String(), "\r\n")
+	if len(lines) != 4 {
+		t.Fatal("Expected 4 lines", len(lines), out.String())
+	}
+	if !strings.Contains(lines[1], "\033[7m") || !strings.Contains(lines[1], "src/web/main.go") {
+		t.Error("First row should be highlighted", lines[1])
+	}
+	if strings.Contains(lines[2], "\033[7m") {
+		t.Error("Only the cursor row should be highlighted", lines[2])
+	}
+	if tm.height != 2 {
+		t.Error("List height should exclude title and help", tm.height)
+	}
+	if len([]rune(lines[3])) > 20 {
+		t.Error("Help line not truncated", lines[3])
+	}
+}
@@ -42,14 +42,15 @@ OPTIONS
 	fs.PrintDefaults()
 	fmt.Fprintln(out)
 	fmt.Fprint(out,
-		`All 'Ignore paths' values are comma-strings allowing multiple values separated
-by commas. To add to defaults instead of replacing them, prefix with '+' such
-as "-ibase +.ssh,.local".
+		`All 'Ignore paths' values as well as -user and -group are comma-strings
+allowing multiple values separated by commas. To add to defaults instead of
+replacing them, prefix with '+' such as "-ibase +.ssh,.local".
 
 The -itypes values can be `, validFTypesString, ` as described in the manpage.
 
 `)
 	fmt.Fprintln(out, "Config:", cfg.configPathHelp)
+	fmt.Fprintf(out, "Environment: %sAGE, %sCOUNT, etc. override config options\n", envPrefix, envPrefix)
 	printVersion(out)
 }
 
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchSettle is how long to wait for further events after the first event of a burst
+// before rescanning. This bounds the rescan rate of busy directories such as log spools.
+const watchSettle = 200 * time.Millisecond
+
+// watchStampFormat prefixes each row printed when a change is detected.
+const watchStampFormat = time.DateTime
+
+// watchEvent is activity within dir or, if gone is true, the demise of dir itself. An
+// overflow event means that some events have been lost.
+type watchEvent struct {
+	dir      string
+	gone     bool
+	overflow bool
+}
+
+// watch keeps the candidate list current after the initial scan by watching every
+// scanned directory for changes until stop is closed. Directories in archives and other
+// virtual trees are not watched.
+//
+// Changes cause just the affected directories to be rescanned. Any new sub-directories
+// found by the rescan are scanned in full and watched in turn. If redraw is true, the
+// whole list is redrawn after each change, as suits a terminal. Otherwise each
+// directory whose activity changed is printed as a row prefixed with a timestamp.
+//
+// The scanner must have been tracking activity prior to the initial scan.
+func (scn *scanner) watch(out io.Writer, redraw bool, stop <-chan struct{}) error {
+	w, err := newDirWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.close()
+
+	scn.watchScanned(w)
+	scn.rebuild(time.Now())
+	if redraw {
+		scn.display(out, redraw, nil)
+	} else {
+		scn.printCandidates(out)
+	}
+
+	events := make(chan []watchEvent)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			evs, err := w.read()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case events <- evs:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	dirty := make(map[string]bool) // Value is true if the directory has gone
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-errs:
+			if errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return err
+		case evs := <-events:
+			for _, ev := range evs {
+				if ev.overflow { // Lost track, so rescan everything
+					fmt.Fprintln(scn.stderr, "Warning: -watch events lost. Rescanning all directories")
+					for dir := range scn.scannedDirs() {
+						if _, ok := dirty[dir]; !ok {
+							dirty[dir] = false
+						}
+					}
+					continue
+				}
+				dirty[ev.dir] = dirty[ev.dir] || ev.gone
+			}
+			if settle == nil {
+				settle = time.After(watchSettle)
+			}
+		case <-settle:
+			settle = nil
+			changed := scn.rescan(w, dirty)
+			dirty = make(map[string]bool)
+			scn.rebuild(time.Now())
+			scn.display(out, redraw, changed)
+		}
+	}
+}
+
+// watchScanned adds a watch for every scanned live directory. Failures are reported but
+// are otherwise harmless as the directory is simply not watched.
+func (scn *scanner) watchScanned(w *dirWatcher) {
+	for dir, sDir := range scn.scannedDirs() {
+		if !sDir.tree.live {
+			continue
+		}
+		if err := w.add(dir); err != nil && !scn.cfg.suppressErrors.v {
+			fmt.Fprintln(scn.stderr, "Error:", err)
+		}
+	}
+}
+
+// rescan rescans the dirty directories and returns the directories whose activity
+// changed as a result. Directories which have gone are forgotten along with everything
+// below them.
+func (scn *scanner) rescan(w *dirWatcher, dirty map[string]bool) (changed []string) {
+	before := make(map[string]candidate, len(scn.activity))
+	for dir, c := range scn.activity {
+		before[dir] = *c
+	}
+
+	scn.baseTime = time.Now()
+	for dir, gone := range dirty {
+		if !gone {
+			continue
+		}
+		below := dir + string(filepath.Separator)
+		for sub := range scn.scanned {
+			if sub == dir || strings.HasPrefix(sub, below) {
+				w.remove(sub)
+				scn.forget(sub)
+			}
+		}
+	}
+
+	// Collect the dirty directories first as each descend updates scanned
+	var sDirs []*scanDir
+	scn.activityMu.Lock()
+	for dir, gone := range dirty {
+		if sDir, ok := scn.scanned[dir]; ok && !gone {
+			sDirs = append(sDirs, sDir)
+		}
+	}
+	scn.activityMu.Unlock()
+	for _, sDir := range sDirs {
+		scn.descend(sDir.tree, sDir.depth, sDir.name)
+	}
+	scn.wait()
+	scn.watchScanned(w)
+
+	for dir, c := range scn.activity {
+		if b, ok := before[dir]; !ok || b.path != c.path || !b.modTime.Equal(c.modTime) ||
+			b.deleted != c.deleted {
+			changed = append(changed, dir)
+		}
+	}
+
+	return
+}
+
+// rebuild replaces allCandidates with the youngest of the tracked activity with ages
+// recalculated relative to now.
+func (scn *scanner) rebuild(now time.Time) {
+	scn.baseTime = now
+	scn.allCandidates = newCandidates(int(scn.cfg.maxCount.v), scn.cfg.maxAge)
+	for _, c := range scn.activity {
+		nc := *c
+		nc.age.setFromTime(now, nc.modTime)
+		scn.futureDated(&nc)
+		scn.allCandidates.addMaybe(&nc)
+	}
+	scn.allCandidates.sortAscending()
+}
+
+// display shows the current candidates. If redraw is true the terminal is cleared and the
+// whole list redrawn. Otherwise only the changed directories are printed with a
+// timestamp prefix.
+func (scn *scanner) display(out io.Writer, redraw bool, changed []string) {
+	now := scn.baseTime
+	if redraw {
+		fmt.Fprint(out, "\033[H\033[2J") // Home and clear screen
+		fmt.Fprintf(out, "%s -watch: %s\n\n", Name, now.Format(watchStampFormat))
+		scn.printCandidates(out)
+		return
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	can := newCandidates(0, age{})
+	for _, dir := range changed {
+		nc := *scn.activity[dir]
+		nc.age.setFromTime(now, nc.modTime)
+		scn.futureDated(&nc)
+		can.addMaybe(&nc)
+	}
+	sortCandidates(can)
+	scn.printPrefixed(out, now.Format(watchStampFormat)+" ", can, can.maxAgeWidth())
+}
+
+// isTerminal returns true if out is a terminal, which is a reasonable indication that
+// redrawing the screen is appropriate.
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// dirWatcher is not supported on this platform. Only inotify is implemented.
+type dirWatcher struct{}
+
+func newDirWatcher() (*dirWatcher, error) {
+	return nil, errors.New("-watch is not supported on this platform")
+}
+
+func (w *dirWatcher) add(dir string) error        { return nil }
+func (w *dirWatcher) remove(dir string)           {}
+func (w *dirWatcher) close() error                { return nil }
+func (w *dirWatcher) read() ([]watchEvent, error) { return nil, nil }
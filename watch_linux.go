@@ -0,0 +1,113 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// watchMask selects the inotify events which imply activity within a directory or the
+// demise of the directory itself.
+const watchMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF | syscall.IN_ONLYDIR | syscall.IN_DONT_FOLLOW
+
+// dirWatcher reports activity in directories via inotify. read() is expected to be
+// called by one goroutine while others add and remove directories. A close() while
+// read() is blocked causes read() to return os.ErrClosed.
+type dirWatcher struct {
+	fd   int
+	f    *os.File // Wraps fd so the runtime poller can interrupt read(). Never call Fd()
+	buf  []byte   // Only accessed by read()
+	mu   sync.Mutex
+	dirs map[int32]string
+	wds  map[string]int32
+}
+
+func newDirWatcher() (*dirWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+
+	return &dirWatcher{fd: fd, f: os.NewFile(uintptr(fd), "inotify"),
+		dirs: make(map[int32]string), wds: make(map[string]int32),
+		buf: make([]byte, 64*1024)}, nil
+}
+
+// add starts watching dir. Adding a directory already being watched is harmless.
+func (w *dirWatcher) add(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.wds[dir]; ok {
+		return nil
+	}
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, watchMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	w.dirs[int32(wd)] = dir
+	w.wds[dir] = int32(wd)
+
+	return nil
+}
+
+// remove stops watching dir.
+func (w *dirWatcher) remove(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if wd, ok := w.wds[dir]; ok {
+		syscall.InotifyRmWatch(w.fd, uint32(wd))
+		delete(w.dirs, wd)
+		delete(w.wds, dir)
+	}
+}
+
+func (w *dirWatcher) close() error {
+	return w.f.Close()
+}
+
+// read blocks until at least one event is available and returns all available events.
+func (w *dirWatcher) read() ([]watchEvent, error) {
+	n, err := w.f.Read(w.buf)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var events []watchEvent
+	for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+		ie := (*syscall.InotifyEvent)(unsafe.Pointer(&w.buf[off]))
+		off += syscall.SizeofInotifyEvent + int(ie.Len)
+
+		if ie.Mask&syscall.IN_Q_OVERFLOW != 0 {
+			events = append(events, watchEvent{overflow: true})
+			continue
+		}
+		dir, ok := w.dirs[ie.Wd]
+		if !ok { // Event for a watch we've already removed
+			continue
+		}
+		switch {
+		case ie.Mask&syscall.IN_IGNORED != 0: // Kernel removed the watch
+			delete(w.dirs, ie.Wd)
+			delete(w.wds, dir)
+			events = append(events, watchEvent{dir: dir, gone: true})
+		case ie.Mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0:
+			events = append(events, watchEvent{dir: dir, gone: true})
+		default:
+			events = append(events, watchEvent{dir: dir})
+		}
+	}
+	if len(events) == 0 && n > 0 && n < syscall.SizeofInotifyEvent {
+		return nil, errors.New("inotify: short read")
+	}
+
+	return events, nil
+}
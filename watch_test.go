@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a concurrency-safe bytes.Buffer so tests can poll output written by a
+// running watch.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.String()
+}
+
+// waitFor polls sb until it contains want beyond offset or a generous time limit is
+// reached.
+func (sb *syncBuffer) waitFor(offset int, want string) bool {
+	for ix := 0; ix < 100; ix++ {
+		if strings.Contains(sb.String()[offset:], want) {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return false
+}
+
+func TestWatch(t *testing.T) {
+	if w, err := newDirWatcher(); err != nil {
+		t.Skip(err)
+	} else {
+		w.close()
+	}
+
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	for _, d := range []string{"quiet", "busy"} {
+		os.Mkdir(filepath.Join(dir, d), 0700)
+		path := filepath.Join(dir, d, "file")
+		os.WriteFile(path, nil, 0600)
+		os.Chtimes(path, old, old)
+		os.Chtimes(filepath.Join(dir, d), old.Add(-time.Hour), old.Add(-time.Hour))
+	}
+	os.Chtimes(dir, old.Add(-time.Hour), old.Add(-time.Hour))
+
+	var stderr bytes.Buffer
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+		func() (string, error) { return "", nil })
+	cfg.ignoreTypes.v = "p" // So that deletions are visible
+	scn, _, err := testScannerSetup(cfg, &stderr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scn.trackActivity()
+	scn.descendRoot(dir)
+	scn.wait()
+
+	var out syncBuffer
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- scn.watch(&out, false, stop)
+	}()
+
+	// Initial list is printed in full
+	if !out.waitFor(0, filepath.Join("quiet", "file")) {
+		t.Fatal("Initial list not printed", out.String())
+	}
+
+	// New activity is printed with a timestamp prefix
+	os.WriteFile(filepath.Join(dir, "busy", "new"), nil, 0600)
+	if !out.waitFor(0, ":f:"+filepath.Join(dir, "busy", "new")+"\n") {
+		t.Fatal("Activity in busy not reported", out.String())
+	}
+
+	// A new sub-directory is scanned and watched in its own right
+	os.Mkdir(filepath.Join(dir, "fresh"), 0700)
+	time.Sleep(2 * watchSettle) // Let the rescan start watching "fresh"
+	os.WriteFile(filepath.Join(dir, "fresh", "log"), nil, 0600)
+	if !out.waitFor(0, ":f:"+filepath.Join(dir, "fresh", "log")+"\n") {
+		t.Fatal("Activity in new directory not reported", out.String())
+	}
+
+	// A removed directory is forgotten and its parent shows the deletion
+	offset := len(out.String())
+	os.RemoveAll(filepath.Join(dir, "quiet"))
+	if !out.waitFor(offset, ":x:"+dir+"\n") {
+		t.Fatal("Deletion not reported", out.String())
+	}
+	if _, ok := scn.activity[filepath.Join(dir, "quiet")]; ok {
+		t.Error("Removed directory should have been forgotten")
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	stamped := lines[len(lines)-1]
+	if _, err := time.ParseInLocation(watchStampFormat, stamped[:len(watchStampFormat)], time.Local); err != nil {
+		t.Error("Change not prefixed with timestamp", stamped, err)
+	}
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error("Unexpected error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("watch did not stop")
+	}
+	if stderr.Len() > 0 {
+		t.Error("Unexpected stderr", stderr.String())
+	}
+}
+
+func TestWatchRedraw(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+		func() (string, error) { return "", nil })
+	scn, _, err := testScannerSetup(cfg, &stderr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scn.trackActivity()
+	scn.descendRoot("testdata/maxdir")
+	scn.wait()
+	scn.rebuild(time.Now())
+
+	var out bytes.Buffer
+	scn.display(&out, true, nil)
+	if !strings.HasPrefix(out.String(), "\033[H\033[2J"+Name+" -watch: ") {
+		t.Error("Redraw should clear the screen and print a header", out.String())
+	}
+	if !strings.Contains(out.String(), filepath.Join("testdata", "maxdir")) {
+		t.Error("Redraw should print the list", out.String())
+	}
+	if isTerminal(&out) {
+		t.Error("A buffer is not a terminal")
+	}
+}
+
+// TestWatchRescan rescans many dirty directories at once, each with sub-directories, so
+// that concurrent scans update scanned while the remaining dirty directories are started.
+// Run with -race.
+func TestWatchRescan(t *testing.T) {
+	w, err := newDirWatcher()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer w.close()
+
+	dir := t.TempDir()
+	var dirs []string
+	for ix := 0; ix < 20; ix++ {
+		d := filepath.Join(dir, fmt.Sprint("d", ix))
+		os.MkdirAll(filepath.Join(d, "sub"), 0700)
+		dirs = append(dirs, d)
+	}
+
+	var stderr bytes.Buffer
+	cfg := newConfig(flag.NewFlagSet(Name, flag.ContinueOnError),
+		func() (string, error) { return "", nil })
+	scn, _, err := testScannerSetup(cfg, &stderr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scn.trackActivity()
+	scn.descendRoot(dir)
+	scn.wait()
+
+	dirty := make(map[string]bool)
+	for _, d := range dirs {
+		os.WriteFile(filepath.Join(d, "new"), nil, 0600)
+		dirty[d] = false
+	}
+	changed := scn.rescan(w, dirty)
+	for _, d := range dirs {
+		if c := scn.activity[d]; c == nil || c.path != filepath.Join(d, "new") {
+			t.Error("Rescan missed", d, c)
+		}
+	}
+	if len(changed) < len(dirs) {
+		t.Error("Expected all dirty directories to change", changed)
+	}
+	if stderr.Len() > 0 {
+		t.Error("Unexpected stderr", stderr.String())
+	}
+}